@@ -0,0 +1,97 @@
+package bench
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ErrorRateMinRequests is how many requests are sent before the error rate
+// is checked against its limit, so a single early failure cannot abort a run.
+const ErrorRateMinRequests = 20
+
+var ErrAborted = errors.New("benchmark aborted")
+
+// WithMaxErrors aborts the run once more than n requests have failed.
+func WithMaxErrors(n int) Option {
+	return func(t *Tester) error {
+		if n < 0 {
+			return fmt.Errorf("%d is invalid max errors", n)
+		}
+		t.maxErrors = n
+		return nil
+	}
+}
+
+// WithMaxErrorRate aborts the run once the fraction of failed requests
+// (0 to 1) goes over rate.
+func WithMaxErrorRate(rate float64) Option {
+	return func(t *Tester) error {
+		if rate < 0 || rate > 1 {
+			return fmt.Errorf("%v is invalid max error rate", rate)
+		}
+		t.maxErrorRate = rate
+		return nil
+	}
+}
+
+func (t Tester) MaxErrors() int {
+	return t.maxErrors
+}
+
+func (t Tester) MaxErrorRate() float64 {
+	return t.maxErrorRate
+}
+
+// ParseRate parses a fraction given either as a percentage, such as "5%",
+// or as a number between 0 and 1.
+func ParseRate(s string) (float64, error) {
+	s = strings.TrimSpace(s)
+	if strings.HasSuffix(s, "%") {
+		v, err := strconv.ParseFloat(strings.TrimSuffix(s, "%"), 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid rate %q", s)
+		}
+		return v / 100, nil
+	}
+	v, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid rate %q", s)
+	}
+	return v, nil
+}
+
+// Abort stops sending new requests. Requests in flight complete and the run
+// is reported as usual, then Run returns ErrAborted. Only the first reason
+// is kept.
+func (t *Tester) Abort(reason string) {
+	t.abortOnce.Do(func() {
+		t.mu.Lock()
+		t.abortReason = reason
+		t.mu.Unlock()
+		t.LogFStdErr("aborting: %s\n", reason)
+		close(t.aborted)
+	})
+}
+
+func (t *Tester) AbortReason() string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.abortReason
+}
+
+func (t *Tester) checkErrorBudget() {
+	t.mu.Lock()
+	requests, failures := t.stats.Requests, t.stats.Failures
+	t.mu.Unlock()
+	if t.maxErrors > 0 && failures > t.maxErrors {
+		t.Abort(fmt.Sprintf("%d errors, over the limit of %d", failures, t.maxErrors))
+	}
+	if t.maxErrorRate > 0 && requests >= ErrorRateMinRequests {
+		rate := float64(failures) / float64(requests)
+		if rate > t.maxErrorRate {
+			t.Abort(fmt.Sprintf("error rate %.2f%%, over the limit of %.2f%%", rate*100, t.maxErrorRate*100))
+		}
+	}
+}
@@ -0,0 +1,200 @@
+package bench_test
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/thiagonache/bench"
+)
+
+func TestRunAbortsOverMaxErrors(t *testing.T) {
+	t.Parallel()
+	server := httptest.NewTLSServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		rw.WriteHeader(http.StatusInternalServerError)
+	}))
+	tester, err := bench.NewTester(
+		bench.WithURL(server.URL),
+		bench.WithRequests(100),
+		bench.WithConcurrency(1),
+		bench.WithMaxErrors(2),
+		bench.WithHTTPClient(server.Client()),
+		bench.WithStdout(io.Discard),
+		bench.WithStderr(io.Discard),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = tester.Run()
+	if !errors.Is(err, bench.ErrAborted) {
+		t.Fatalf("want ErrAborted, got %v", err)
+	}
+	stats := tester.Stats()
+	if stats.Requests > 4 {
+		t.Errorf("want run aborted after 3 errors, got %d requests", stats.Requests)
+	}
+	if tester.AbortReason() == "" {
+		t.Error("want abort reason")
+	}
+}
+
+func TestRunAbortsOverMaxErrorRate(t *testing.T) {
+	t.Parallel()
+	server := httptest.NewTLSServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		rw.WriteHeader(http.StatusInternalServerError)
+	}))
+	tester, err := bench.NewTester(
+		bench.WithURL(server.URL),
+		bench.WithRequests(1000),
+		bench.WithConcurrency(1),
+		bench.WithMaxErrorRate(0.5),
+		bench.WithHTTPClient(server.Client()),
+		bench.WithStdout(io.Discard),
+		bench.WithStderr(io.Discard),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = tester.Run()
+	if !errors.Is(err, bench.ErrAborted) {
+		t.Fatalf("want ErrAborted, got %v", err)
+	}
+	stats := tester.Stats()
+	if stats.Requests < bench.ErrorRateMinRequests || stats.Requests > bench.ErrorRateMinRequests+1 {
+		t.Errorf("want run aborted after %d requests, got %d", bench.ErrorRateMinRequests, stats.Requests)
+	}
+}
+
+func TestRunWithinErrorBudgetCompletes(t *testing.T) {
+	t.Parallel()
+	server := httptest.NewTLSServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {}))
+	tester, err := bench.NewTester(
+		bench.WithURL(server.URL),
+		bench.WithRequests(30),
+		bench.WithMaxErrors(1),
+		bench.WithMaxErrorRate(0.01),
+		bench.WithHTTPClient(server.Client()),
+		bench.WithStdout(io.Discard),
+		bench.WithStderr(io.Discard),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = tester.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tester.Stats().Requests != 30 {
+		t.Errorf("want 30 requests, got %d", tester.Stats().Requests)
+	}
+}
+
+func TestParseRate(t *testing.T) {
+	t.Parallel()
+	tests := map[string]float64{
+		"5%":   0.05,
+		"0.5%": 0.005,
+		"0.25": 0.25,
+	}
+	for s, want := range tests {
+		got, err := bench.ParseRate(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want != got {
+			t.Errorf("%q: want %v, got %v", s, want, got)
+		}
+	}
+	_, err := bench.ParseRate("five")
+	if err == nil {
+		t.Error("want error for invalid rate")
+	}
+}
+
+func TestFromArgsErrorBudgetFlagsConfigureLimits(t *testing.T) {
+	t.Parallel()
+	tester, err := bench.NewTester(
+		bench.FromArgs([]string{"run", "-u", "https://example.com", "-max-errors", "10", "-max-error-rate", "5%"}),
+		bench.WithStderr(io.Discard),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tester.MaxErrors() != 10 {
+		t.Errorf("want max errors 10, got %d", tester.MaxErrors())
+	}
+	if tester.MaxErrorRate() != 0.05 {
+		t.Errorf("want max error rate 0.05, got %v", tester.MaxErrorRate())
+	}
+}
+
+func TestNewTesterWithInvalidErrorBudgetReturnsError(t *testing.T) {
+	t.Parallel()
+	for name, opt := range map[string]bench.Option{
+		"max errors":     bench.WithMaxErrors(-1),
+		"max error rate": bench.WithMaxErrorRate(1.5),
+	} {
+		_, err := bench.NewTester(
+			bench.WithURL("https://example.com"),
+			opt,
+			bench.WithStderr(io.Discard),
+		)
+		if err == nil {
+			t.Errorf("%s: want error", name)
+		}
+	}
+}
+
+func TestRunReportsAbortAndErrorsWhenEveryRequestFails(t *testing.T) {
+	t.Parallel()
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {}))
+	URL := server.URL
+	server.Close()
+	stdout := &bytes.Buffer{}
+	tester, err := bench.NewTester(
+		bench.FromArgs([]string{"run", "-u", URL, "-r", "50", "-max-errors", "2", "-threshold", "errors<1%", "-threshold", "p95<1s"}),
+		bench.WithStdout(stdout),
+		bench.WithStderr(io.Discard),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = tester.Run()
+	if !errors.Is(err, bench.ErrAborted) {
+		t.Errorf("want ErrAborted, got %v", err)
+	}
+	for _, want := range []string{
+		"Requests: 3 Success: 0 Failures: 3\n",
+		"Errors: " + bench.ErrorClassConnect + ": 3\n",
+		"Threshold errors<1%: FAIL (errors 100.00%)\n",
+		"Threshold p95<1s: FAIL (p95 not recorded)\n",
+		"Aborted: 3 errors, over the limit of 2\n",
+	} {
+		if !strings.Contains(stdout.String(), want) {
+			t.Errorf("want %q in output, got:\n%s", want, stdout)
+		}
+	}
+}
+
+func TestRunReturnsFailedThresholdsWhenEveryRequestFails(t *testing.T) {
+	t.Parallel()
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {}))
+	URL := server.URL
+	server.Close()
+	tester, err := bench.NewTester(
+		bench.FromArgs([]string{"run", "-u", URL, "-r", "5", "-threshold", "errors<1%"}),
+		bench.WithStdout(io.Discard),
+		bench.WithStderr(io.Discard),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = tester.Run()
+	if !errors.Is(err, bench.ErrThresholdsFailed) {
+		t.Errorf("want ErrThresholdsFailed, got %v", err)
+	}
+}
@@ -0,0 +1,297 @@
+package bench
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+const (
+	ReasonStatus     = "status"
+	ReasonHeader     = "header"
+	ReasonBody       = "body"
+	ReasonBodyRegexp = "body regexp"
+	ReasonJSON       = "json"
+	ReasonBodySize   = "body size"
+)
+
+// StatusRange is an inclusive range of accepted status codes.
+type StatusRange struct {
+	Min, Max int
+}
+
+// DefaultAcceptedStatus accepts any 2xx response.
+var DefaultAcceptedStatus = []StatusRange{{Min: 200, Max: 299}}
+
+// ParseStatusRanges parses a comma-separated list of status codes, ranges
+// and classes, such as "200,201,300-399" or "2xx,404".
+func ParseStatusRanges(s string) ([]StatusRange, error) {
+	ranges := []StatusRange{}
+	for _, field := range strings.Split(s, ",") {
+		field = strings.TrimSpace(field)
+		if len(field) == 3 && strings.HasSuffix(field, "xx") {
+			class, err := strconv.Atoi(field[:1])
+			if err != nil || class < 1 || class > 5 {
+				return nil, fmt.Errorf("invalid status class %q", field)
+			}
+			ranges = append(ranges, StatusRange{Min: class * 100, Max: class*100 + 99})
+			continue
+		}
+		bounds := strings.SplitN(field, "-", 2)
+		min, err := strconv.Atoi(bounds[0])
+		if err != nil {
+			return nil, fmt.Errorf("invalid status code %q", field)
+		}
+		max := min
+		if len(bounds) == 2 {
+			max, err = strconv.Atoi(bounds[1])
+			if err != nil {
+				return nil, fmt.Errorf("invalid status code %q", field)
+			}
+		}
+		if min < 100 || max > 599 || max < min {
+			return nil, fmt.Errorf("invalid status code %q", field)
+		}
+		ranges = append(ranges, StatusRange{Min: min, Max: max})
+	}
+	return ranges, nil
+}
+
+// AssertionError is a response that does not meet the success criteria.
+// Reason is one of the Reason constants, followed by the header name or JSON
+// path when there is one.
+type AssertionError struct {
+	Reason     string
+	Detail     string
+	StatusCode int
+}
+
+func (e *AssertionError) Error() string {
+	return e.Detail
+}
+
+type jsonAssertion struct {
+	path, value string
+}
+
+type assertions struct {
+	status       []StatusRange
+	headers      http.Header
+	bodyContains []string
+	bodyMatches  []*regexp.Regexp
+	json         []jsonAssertion
+	maxBodySize  int64
+}
+
+func WithAcceptedStatus(ranges ...StatusRange) Option {
+	return func(t *Tester) error {
+		if len(ranges) == 0 {
+			return ErrValueCannotBeNil
+		}
+		t.assertions.status = ranges
+		return nil
+	}
+}
+
+// WithRequiredHeader fails responses without the header or, when value is
+// not empty, without a value containing it.
+func WithRequiredHeader(key, value string) Option {
+	return func(t *Tester) error {
+		t.assertions.headers.Add(key, value)
+		return nil
+	}
+}
+
+func WithBodyContains(s string) Option {
+	return func(t *Tester) error {
+		t.assertions.bodyContains = append(t.assertions.bodyContains, s)
+		return nil
+	}
+}
+
+func WithBodyMatches(expr string) Option {
+	return func(t *Tester) error {
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			return err
+		}
+		t.assertions.bodyMatches = append(t.assertions.bodyMatches, re)
+		return nil
+	}
+}
+
+// WithJSONPath fails responses whose JSON body does not have value at path.
+// Paths are dot separated, with array indexes as numbers or in brackets,
+// such as "data.items.0.id" or "data.items[0].id". Strings are compared
+// as they are and other values as JSON, so "true" or "42" match.
+func WithJSONPath(path, value string) Option {
+	return func(t *Tester) error {
+		t.assertions.json = append(t.assertions.json, jsonAssertion{path: path, value: value})
+		return nil
+	}
+}
+
+func WithMaxBodySize(size int64) Option {
+	return func(t *Tester) error {
+		if size < 1 {
+			return fmt.Errorf("%d is invalid max body size", size)
+		}
+		t.assertions.maxBodySize = size
+		return nil
+	}
+}
+
+func (a assertions) readsBody() bool {
+	return len(a.bodyContains) > 0 || len(a.bodyMatches) > 0 || len(a.json) > 0 || a.maxBodySize > 0
+}
+
+// checkResponse returns an *AssertionError for the first criterion the
+// response does not meet.
+func (t *Tester) checkResponse(resp *http.Response) error {
+	a := t.assertions
+	accepted := false
+	for _, r := range a.status {
+		if resp.StatusCode >= r.Min && resp.StatusCode <= r.Max {
+			accepted = true
+			break
+		}
+	}
+	if !accepted {
+		return &AssertionError{
+			Reason:     ReasonStatus,
+			Detail:     fmt.Sprintf("unexpected status code %d", resp.StatusCode),
+			StatusCode: resp.StatusCode,
+		}
+	}
+	for key, values := range a.headers {
+		got, ok := resp.Header[key]
+		if !ok {
+			return &AssertionError{
+				Reason: ReasonHeader + " " + key,
+				Detail: fmt.Sprintf("missing header %s", key),
+			}
+		}
+		for _, want := range values {
+			if want != "" && !containsSubstring(got, want) {
+				return &AssertionError{
+					Reason: ReasonHeader + " " + key,
+					Detail: fmt.Sprintf("want header %s %q, got %q", key, want, got),
+				}
+			}
+		}
+	}
+	if !a.readsBody() {
+		return nil
+	}
+	r := resp.Body
+	if a.maxBodySize > 0 {
+		r = io.NopCloser(io.LimitReader(resp.Body, a.maxBodySize+1))
+	}
+	body, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	if a.maxBodySize > 0 && int64(len(body)) > a.maxBodySize {
+		return &AssertionError{
+			Reason: ReasonBodySize,
+			Detail: fmt.Sprintf("body larger than %d bytes", a.maxBodySize),
+		}
+	}
+	for _, s := range a.bodyContains {
+		if !strings.Contains(string(body), s) {
+			return &AssertionError{
+				Reason: ReasonBody,
+				Detail: fmt.Sprintf("body does not contain %q", s),
+			}
+		}
+	}
+	for _, re := range a.bodyMatches {
+		if !re.Match(body) {
+			return &AssertionError{
+				Reason: ReasonBodyRegexp,
+				Detail: fmt.Sprintf("body does not match %q", re),
+			}
+		}
+	}
+	if len(a.json) == 0 {
+		return nil
+	}
+	var doc interface{}
+	err = json.Unmarshal(body, &doc)
+	if err != nil {
+		return &AssertionError{
+			Reason: ReasonJSON,
+			Detail: fmt.Sprintf("invalid JSON body: %v", err),
+		}
+	}
+	for _, j := range a.json {
+		got, err := jsonPath(doc, j.path)
+		if err == nil && got != j.value {
+			err = fmt.Errorf("want %q, got %q", j.value, got)
+		}
+		if err != nil {
+			return &AssertionError{
+				Reason: ReasonJSON + " " + j.path,
+				Detail: fmt.Sprintf("JSON path %s: %v", j.path, err),
+			}
+		}
+	}
+	return nil
+}
+
+// jsonPath returns the value at path formatted for comparison: strings as
+// they are and anything else as JSON.
+func jsonPath(doc interface{}, path string) (string, error) {
+	path = strings.NewReplacer("[", ".", "]", "").Replace(path)
+	v := doc
+	for _, key := range strings.Split(path, ".") {
+		if key == "" {
+			continue
+		}
+		switch node := v.(type) {
+		case map[string]interface{}:
+			child, ok := node[key]
+			if !ok {
+				return "", fmt.Errorf("no key %q", key)
+			}
+			v = child
+		case []interface{}:
+			i, err := strconv.Atoi(key)
+			if err != nil || i < 0 || i >= len(node) {
+				return "", fmt.Errorf("no index %q", key)
+			}
+			v = node[i]
+		default:
+			return "", fmt.Errorf("no key %q", key)
+		}
+	}
+	if s, ok := v.(string); ok {
+		return s, nil
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+func containsSubstring(values []string, s string) bool {
+	for _, v := range values {
+		if strings.Contains(v, s) {
+			return true
+		}
+	}
+	return false
+}
+
+func parseJSONAssertion(s string) (string, string, error) {
+	fields := strings.SplitN(s, "=", 2)
+	if len(fields) != 2 || strings.TrimSpace(fields[0]) == "" {
+		return "", "", fmt.Errorf("invalid JSON assertion %q, want 'path=value'", s)
+	}
+	return strings.TrimSpace(fields[0]), strings.TrimSpace(fields[1]), nil
+}
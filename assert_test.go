@@ -0,0 +1,196 @@
+package bench_test
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/thiagonache/bench"
+)
+
+func TestParseStatusRanges(t *testing.T) {
+	t.Parallel()
+	want := []bench.StatusRange{
+		{Min: 200, Max: 200},
+		{Min: 201, Max: 204},
+		{Min: 300, Max: 399},
+	}
+	got, err := bench.ParseStatusRanges("200, 201-204,3xx")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !cmp.Equal(want, got) {
+		t.Error(cmp.Diff(want, got))
+	}
+}
+
+func TestParseStatusRangesInvalidReturnsError(t *testing.T) {
+	t.Parallel()
+	for _, s := range []string{"", "abc", "99", "600", "300-200", "9xx", "2xy"} {
+		_, err := bench.ParseStatusRanges(s)
+		if err == nil {
+			t.Errorf("want error for %q", s)
+		}
+	}
+}
+
+func TestRunAcceptsAny2xxStatusByDefault(t *testing.T) {
+	t.Parallel()
+	server := httptest.NewTLSServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		rw.WriteHeader(http.StatusNoContent)
+	}))
+	tester, err := bench.NewTester(
+		bench.WithURL(server.URL),
+		bench.WithRequests(5),
+		bench.WithHTTPClient(server.Client()),
+		bench.WithStdout(io.Discard),
+		bench.WithStderr(io.Discard),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = tester.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stats := tester.Stats()
+	if stats.Successes != 5 {
+		t.Errorf("want 5 successes, got %d", stats.Successes)
+	}
+}
+
+func TestRunCountsAssertionFailuresByReason(t *testing.T) {
+	t.Parallel()
+	server := httptest.NewTLSServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		rw.Header().Set("Content-Type", "application/json; charset=utf-8")
+		fmt.Fprint(rw, `{"data": {"items": [{"id": 42, "name": "bench"}]}, "ok": true}`)
+	}))
+	tests := map[string]struct {
+		opts []bench.Option
+		want map[string]int
+	}{
+		"passing": {
+			opts: []bench.Option{
+				bench.WithAcceptedStatus(bench.StatusRange{Min: 200, Max: 200}),
+				bench.WithRequiredHeader("Content-Type", "application/json"),
+				bench.WithBodyContains(`"name": "bench"`),
+				bench.WithBodyMatches(`"id": [0-9]+`),
+				bench.WithJSONPath("data.items[0].id", "42"),
+				bench.WithJSONPath("data.items.0.name", "bench"),
+				bench.WithJSONPath("ok", "true"),
+				bench.WithMaxBodySize(1024),
+			},
+		},
+		"status": {
+			opts: []bench.Option{bench.WithAcceptedStatus(bench.StatusRange{Min: 201, Max: 201})},
+			want: map[string]int{bench.ReasonStatus: 1},
+		},
+		"header": {
+			opts: []bench.Option{bench.WithRequiredHeader("X-Request-Id", "")},
+			want: map[string]int{"header X-Request-Id": 1},
+		},
+		"header value": {
+			opts: []bench.Option{bench.WithRequiredHeader("Content-Type", "text/html")},
+			want: map[string]int{"header Content-Type": 1},
+		},
+		"body": {
+			opts: []bench.Option{bench.WithBodyContains("error")},
+			want: map[string]int{bench.ReasonBody: 1},
+		},
+		"body regexp": {
+			opts: []bench.Option{bench.WithBodyMatches(`"id": "[a-z]+"`)},
+			want: map[string]int{bench.ReasonBodyRegexp: 1},
+		},
+		"json": {
+			opts: []bench.Option{bench.WithJSONPath("data.items.0.id", "43")},
+			want: map[string]int{"json data.items.0.id": 1},
+		},
+		"json missing": {
+			opts: []bench.Option{bench.WithJSONPath("data.items.1.id", "42")},
+			want: map[string]int{"json data.items.1.id": 1},
+		},
+		"body size": {
+			opts: []bench.Option{bench.WithMaxBodySize(10)},
+			want: map[string]int{bench.ReasonBodySize: 1},
+		},
+	}
+	for name, tc := range tests {
+		opts := append([]bench.Option{
+			bench.WithURL(server.URL),
+			bench.WithHTTPClient(server.Client()),
+			bench.WithStdout(io.Discard),
+			bench.WithStderr(io.Discard),
+		}, tc.opts...)
+		tester, err := bench.NewTester(opts...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = tester.Run()
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := tester.Stats().AssertionFailures
+		if !cmp.Equal(tc.want, got) {
+			t.Errorf("%s: %s", name, cmp.Diff(tc.want, got))
+		}
+	}
+}
+
+func TestFromArgsAssertionFlagsConfigureAssertions(t *testing.T) {
+	t.Parallel()
+	server := httptest.NewTLSServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		rw.Header().Set("X-Version", "v2")
+		rw.WriteHeader(http.StatusCreated)
+		fmt.Fprint(rw, `{"status": "created"}`)
+	}))
+	tester, err := bench.NewTester(
+		bench.FromArgs([]string{
+			"run", "-u", server.URL, "-r", "2",
+			"-status", "201",
+			"-expect-header", "X-Version: v2",
+			"-expect-header", "Content-Type",
+			"-expect-body", "created",
+			"-expect-body-regex", `^\{.*\}$`,
+			"-expect-json", "status=created",
+			"-max-body-size", "100",
+		}),
+		bench.WithHTTPClient(server.Client()),
+		bench.WithStdout(io.Discard),
+		bench.WithStderr(io.Discard),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = tester.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stats := tester.Stats()
+	if stats.Successes != 2 || stats.AssertionFailures != nil {
+		t.Errorf("want 2 successes and no assertion failures, got %d and %v", stats.Successes, stats.AssertionFailures)
+	}
+}
+
+func TestNewTesterWithInvalidAssertionsReturnsError(t *testing.T) {
+	t.Parallel()
+	options := map[string]bench.Option{
+		"status":        bench.WithAcceptedStatus(),
+		"body regexp":   bench.WithBodyMatches("("),
+		"max body size": bench.WithMaxBodySize(0),
+		"json flag":     bench.FromArgs([]string{"run", "-u", "https://example.com", "-expect-json", "status"}),
+		"status flag":   bench.FromArgs([]string{"run", "-u", "https://example.com", "-status", "2xy"}),
+	}
+	for name, opt := range options {
+		_, err := bench.NewTester(
+			bench.WithURL("https://example.com"),
+			opt,
+			bench.WithStderr(io.Discard),
+		)
+		if err == nil {
+			t.Errorf("%s: want error", name)
+		}
+	}
+}
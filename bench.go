@@ -1,14 +1,14 @@
 package bench
 
 import (
-	"bufio"
+	"encoding/json"
 	"errors"
 	"flag"
 	"fmt"
 	"io"
 	"math"
 	"net/http"
-	"net/url"
+	"net/http/httptrace"
 	"os"
 	"sort"
 	"strconv"
@@ -32,6 +32,7 @@ var (
 	DefaultHTTPClient = &http.Client{
 		Timeout: 5 * time.Second,
 	}
+	DefaultPercentiles  = []float64{50, 90, 99}
 	ErrNoArgs           = errors.New("no arguments")
 	ErrNoURL            = errors.New("no URL to test")
 	ErrTimeNotRecorded  = errors.New("no execution time recorded")
@@ -39,37 +40,96 @@ var (
 )
 
 type Tester struct {
-	Concurrency    int
-	client         *http.Client
-	EndAt          time.Duration
-	ExportStats    bool
-	Graphs         bool
-	OutputPath     string
-	requests       int
-	startAt        time.Time
-	stdout, stderr io.Writer
-	URL            string
-	userAgent      string
-	wg             *sync.WaitGroup
-	Work           chan struct{}
-
-	mu           *sync.Mutex
-	stats        Stats
-	TimeRecorder TimeRecorder
+	abortOnce        *sync.Once
+	abortReason      string
+	aborted          chan struct{}
+	assertions       assertions
+	body             []byte
+	Concurrency      int
+	client           *http.Client
+	duration         time.Duration
+	EndAt            time.Duration
+	endpointNames    []string
+	endpoints        map[string]*endpointStats
+	ExportStats      bool
+	expectedInterval time.Duration
+	Graphs           bool
+	header           http.Header
+	maxErrorRate     float64
+	maxErrors        int
+	method           string
+	OutputPath       string
+	percentiles      []float64
+	progressInterval time.Duration
+	rate             float64
+	replay           []Endpoint
+	replaySpeed      float64
+	requests         int
+	resultLogFormat  string
+	resultLogPath    string
+	resultLogWriter  io.Writer
+	results          []Result
+	scenario         Scenario
+	seed             int64
+	seq              int
+	seriesFormat     string
+	seriesInterval   time.Duration
+	source           RequestSource
+	stages           []Stage
+	startAt          time.Time
+	statsFormat      string
+	statsSamples     bool
+	stdin            io.Reader
+	stdout, stderr   io.Writer
+	templateData     []map[string]string
+	thresholdAbort   bool
+	thresholds       []Threshold
+	URL              string
+	userAgent        string
+	warmup           time.Duration
+	warmupReport     bool
+	warmupRequests   int
+	wg               *sync.WaitGroup
+	Work             chan time.Time
+	workers          int
+
+	mu                    *sync.Mutex
+	newRecorder           func() (Recorder, error)
+	overThreshold         []int
+	phaseRecorders        map[string]Recorder
+	progress              *progress
+	resultLog             *resultLog
+	stageStats            []*endpointStats
+	stats                 Stats
+	timeSeries            *timeSeries
+	TimeRecorder          Recorder
+	CorrectedTimeRecorder Recorder
+	warmupStats           *endpointStats
 }
 
 func NewTester(opts ...Option) (*Tester, error) {
 	tester := &Tester{
+		abortOnce: &sync.Once{},
+		aborted:   make(chan struct{}),
+		assertions: assertions{
+			status:  DefaultAcceptedStatus,
+			headers: http.Header{},
+		},
 		client:      DefaultHTTPClient,
 		Concurrency: DefaultConcurrency,
+		endpoints:   map[string]*endpointStats{},
+		header:      http.Header{},
+		method:      http.MethodGet,
 		OutputPath:  DefaultOutputPath,
+		percentiles: DefaultPercentiles,
 		requests:    DefaultNumRequests,
+		seed:        time.Now().UnixNano(),
 		stats:       Stats{},
 		stderr:      os.Stderr,
+		stdin:       os.Stdin,
 		stdout:      os.Stdout,
-		TimeRecorder: TimeRecorder{
-			ExecutionsTime: []float64{},
-			mu:             &sync.Mutex{},
+		newRecorder: func() (Recorder, error) {
+			return NewTimeRecorder(), nil
 		},
 		userAgent: DefaultUserAgent,
 		wg:        &sync.WaitGroup{},
@@ -81,20 +141,58 @@ func NewTester(opts ...Option) (*Tester, error) {
 			return nil, err
 		}
 	}
-	if tester.URL == "" {
-		return nil, ErrNoURL
+	if tester.requests < 1 {
+		return nil, fmt.Errorf("%d is invalid number of requests", tester.requests)
+	}
+	if tester.duration < 0 {
+		return nil, fmt.Errorf("%v is invalid duration", tester.duration)
+	}
+	if tester.rate < 0 {
+		return nil, fmt.Errorf("%v is invalid rate", tester.rate)
+	}
+	if tester.expectedInterval < 0 {
+		return nil, fmt.Errorf("%v is invalid expected interval", tester.expectedInterval)
 	}
-	u, err := url.Parse(tester.URL)
+	var err error
+	tester.TimeRecorder, err = tester.newRecorder()
 	if err != nil {
 		return nil, err
 	}
-	if u.Host == "" {
-		return nil, fmt.Errorf("invalid URL %q", u)
+	tester.CorrectedTimeRecorder, err = tester.newRecorder()
+	if err != nil {
+		return nil, err
 	}
-	if tester.requests < 1 {
-		return nil, fmt.Errorf("%d is invalid number of requests", tester.requests)
+	tester.phaseRecorders = map[string]Recorder{}
+	for _, phase := range Phases {
+		tester.phaseRecorders[phase], err = tester.newRecorder()
+		if err != nil {
+			return nil, err
+		}
+	}
+	err = tester.setupStages()
+	if err != nil {
+		return nil, err
+	}
+	err = tester.setupSource()
+	if err != nil {
+		return nil, err
+	}
+	err = tester.setupWarmup()
+	if err != nil {
+		return nil, err
+	}
+	tester.setupThresholds()
+	if tester.seriesInterval > 0 {
+		tester.timeSeries = &timeSeries{
+			mu:       &sync.Mutex{},
+			interval: tester.seriesInterval,
+		}
 	}
-	tester.Work = make(chan struct{})
+	err = tester.validateTemplates()
+	if err != nil {
+		return nil, err
+	}
+	tester.Work = make(chan time.Time)
 	return tester, nil
 }
 
@@ -104,9 +202,48 @@ func FromArgs(args []string) Option {
 		fs.SetOutput(t.stderr)
 		reqs := fs.Int("r", 1, "number of requests to be performed in the benchmark")
 		graphs := fs.Bool("g", false, "generate graphs")
-		exportStats := fs.Bool("s", false, "generate stats file")
-		concurrency := fs.Int("c", 1, "number of concurrent requests (users) to run benchmark")
+		exportStats := fs.Bool("s", false, "generate stats file, stats.json or stats.csv")
+		concurrency := fs.Int("c", 1, "number of concurrent requests (users) to run benchmark, or maximum workers with -rate")
 		url := fs.String("u", "", "url to run benchmark")
+		duration := fs.Duration("d", 0, "duration of the benchmark (e.g. 30s), overrides -r")
+		rate := fs.Float64("rate", 0, "send requests at a constant arrival rate (requests per second) instead of as fast as possible")
+		expectedInterval := fs.Duration("expected-interval", 0, "expected interval between requests, back-fills samples to correct latency for coordinated omission")
+		hdr := fs.Int("hdr", 0, "record latencies in an HDR histogram with this many significant figures (1-5) instead of keeping every sample")
+		percentiles := fs.String("p", "", "comma-separated percentiles to report (e.g. 50,75,95,99.9)")
+		method := fs.String("m", http.MethodGet, "HTTP method")
+		headers := repeatedFlag{}
+		fs.Var(&headers, "H", "HTTP header to send, as 'Key: Value' (repeatable)")
+		body := fs.String("b", "", "request body")
+		bodyFile := fs.String("body-file", "", "file to read the request body from, or - for stdin")
+		scenarioFile := fs.String("f", "", "scenario file (YAML or JSON) listing weighted requests, instead of -u")
+		replayFile := fs.String("replay", "", "HAR file or access log to replay, against -u if set")
+		status := fs.String("status", "", "accepted status codes, ranges and classes (e.g. 200,201,3xx), default 2xx")
+		expectHeaders := repeatedFlag{}
+		fs.Var(&expectHeaders, "expect-header", "fail responses without this header, as 'Key' or 'Key: Value' (repeatable)")
+		expectBody := fs.String("expect-body", "", "fail responses whose body does not contain this text")
+		expectBodyRegexp := fs.String("expect-body-regex", "", "fail responses whose body does not match this regular expression")
+		expectJSON := repeatedFlag{}
+		fs.Var(&expectJSON, "expect-json", "fail responses whose JSON body does not have this value, as 'path=value' (repeatable)")
+		thresholds := repeatedFlag{}
+		fs.Var(&thresholds, "threshold", "fail the run unless its stats meet this, such as p95<200ms, errors<0.5% or rps>1000 (repeatable)")
+		thresholdAbort := fs.Bool("threshold-abort", false, "abort the run as soon as a threshold can no longer be met")
+		maxBodySize := fs.Int64("max-body-size", 0, "fail responses whose body is larger than this many bytes")
+		maxErrors := fs.Int("max-errors", 0, "abort the benchmark once more than this many requests failed")
+		maxErrorRate := fs.String("max-error-rate", "", "abort the benchmark once the error rate goes over this (e.g. 5%)")
+		seed := fs.Int64("seed", 0, "seed for the random values of templates and the order of scenario endpoints, to send reproducible requests")
+		templateData := fs.String("data", "", "CSV file whose rows, in turn, are available to templates as .Row")
+		replaySpeed := fs.Float64("replay-speed", 0, "replay requests at their original timing scaled by this factor (e.g. 1 or 2), instead of as fast as possible")
+		warmup := fs.Duration("warmup", 0, "send requests for this long before the benchmark, leaving them out of its statistics")
+		warmupRequests := fs.Int("warmup-requests", 0, "send this many requests before the benchmark, leaving them out of its statistics")
+		warmupReport := fs.Bool("warmup-report", false, "print the statistics of the warm-up requests")
+		progress := fs.Duration("progress", 0, "report progress on stderr at this interval (e.g. 5s)")
+		timeSeries := fs.Duration("timeseries", 0, "report statistics for every interval of this length (e.g. 1s), written to timeseries.csv or .json")
+		timeSeriesFormat := fs.String("timeseries-format", FormatCSV, "format of the time series, csv or json")
+		resultLog := fs.String("log", "", "file to write the result of every request to, as CSV if it ends in .csv and JSON Lines otherwise")
+		results := fs.String("load", "", "result log of a previous run to compute statistics, graphs and exports from, instead of sending requests")
+		statsFormat := fs.String("stats-format", FormatJSON, "format of the stats file, json or csv")
+		statsSamples := fs.Bool("samples", false, "write the latency of every request to the stats file, so that comparisons can test the significance of changes")
+		stages := fs.String("stages", "", "comma-separated stages as duration:workers, ramping the workers linearly (e.g. 1m:200,5m:200,30s:0), instead of -c and -d")
 		if len(args) < 1 {
 			fs.Usage()
 			return ErrNoArgs
@@ -119,6 +256,177 @@ func FromArgs(args []string) Option {
 			t.Graphs = *graphs
 			t.Concurrency = *concurrency
 			t.ExportStats = *exportStats
+			t.duration = *duration
+			t.rate = *rate
+			t.expectedInterval = *expectedInterval
+			if *hdr > 0 {
+				err := WithHDRRecorder(*hdr)(t)
+				if err != nil {
+					return err
+				}
+			}
+			if *percentiles != "" {
+				ps, err := ParsePercentiles(*percentiles)
+				if err != nil {
+					return err
+				}
+				err = WithPercentiles(ps...)(t)
+				if err != nil {
+					return err
+				}
+			}
+			t.method = *method
+			for _, h := range headers {
+				key, value, err := parseHeader(h)
+				if err != nil {
+					return err
+				}
+				t.header.Add(key, value)
+			}
+			if *body != "" && *bodyFile != "" {
+				return errors.New("-b and -body-file cannot be used together")
+			}
+			if *body != "" {
+				t.body = []byte(*body)
+			}
+			if *bodyFile != "" {
+				err := WithBodyFile(*bodyFile)(t)
+				if err != nil {
+					return err
+				}
+			}
+			if *scenarioFile != "" {
+				err := WithScenarioFile(*scenarioFile)(t)
+				if err != nil {
+					return err
+				}
+			}
+			if *replayFile != "" {
+				err := WithReplayFile(*replayFile)(t)
+				if err != nil {
+					return err
+				}
+			}
+			t.replaySpeed = *replaySpeed
+			if *status != "" {
+				ranges, err := ParseStatusRanges(*status)
+				if err != nil {
+					return err
+				}
+				t.assertions.status = ranges
+			}
+			for _, h := range expectHeaders {
+				key, value := strings.TrimSpace(h), ""
+				if strings.Contains(h, ":") {
+					var err error
+					key, value, err = parseHeader(h)
+					if err != nil {
+						return err
+					}
+				}
+				t.assertions.headers.Add(key, value)
+			}
+			if *expectBody != "" {
+				t.assertions.bodyContains = append(t.assertions.bodyContains, *expectBody)
+			}
+			if *expectBodyRegexp != "" {
+				err := WithBodyMatches(*expectBodyRegexp)(t)
+				if err != nil {
+					return err
+				}
+			}
+			for _, j := range expectJSON {
+				path, value, err := parseJSONAssertion(j)
+				if err != nil {
+					return err
+				}
+				t.assertions.json = append(t.assertions.json, jsonAssertion{path: path, value: value})
+			}
+			if *maxBodySize != 0 {
+				err := WithMaxBodySize(*maxBodySize)(t)
+				if err != nil {
+					return err
+				}
+			}
+			err := WithMaxErrors(*maxErrors)(t)
+			if err != nil {
+				return err
+			}
+			if *maxErrorRate != "" {
+				rate, err := ParseRate(*maxErrorRate)
+				if err != nil {
+					return err
+				}
+				err = WithMaxErrorRate(rate)(t)
+				if err != nil {
+					return err
+				}
+			}
+			if *seed != 0 {
+				t.seed = *seed
+			}
+			if *templateData != "" {
+				err := WithTemplateDataFile(*templateData)(t)
+				if err != nil {
+					return err
+				}
+			}
+			err = WithWarmup(*warmup)(t)
+			if err != nil {
+				return err
+			}
+			err = WithWarmupRequests(*warmupRequests)(t)
+			if err != nil {
+				return err
+			}
+			t.warmupReport = *warmupReport
+			t.statsSamples = *statsSamples
+			for _, expr := range thresholds {
+				th, err := ParseThreshold(expr)
+				if err != nil {
+					return err
+				}
+				t.thresholds = append(t.thresholds, th)
+			}
+			t.thresholdAbort = *thresholdAbort
+			err = WithProgress(*progress)(t)
+			if err != nil {
+				return err
+			}
+			err = WithTimeSeries(*timeSeries)(t)
+			if err != nil {
+				return err
+			}
+			err = WithTimeSeriesFormat(*timeSeriesFormat)(t)
+			if err != nil {
+				return err
+			}
+			err = WithStatsFormat(*statsFormat)(t)
+			if err != nil {
+				return err
+			}
+			if *resultLog != "" {
+				err = WithResultLogFile(*resultLog)(t)
+				if err != nil {
+					return err
+				}
+			}
+			if *results != "" {
+				err = WithResultsFile(*results)(t)
+				if err != nil {
+					return err
+				}
+			}
+			if *stages != "" {
+				ss, err := ParseStages(*stages)
+				if err != nil {
+					return err
+				}
+				err = WithStages(ss...)(t)
+				if err != nil {
+					return err
+				}
+			}
 		default:
 			return errors.New("expected run or cmp subcommands")
 		}
@@ -133,6 +441,79 @@ func WithRequests(reqs int) Option {
 	}
 }
 
+func WithDuration(d time.Duration) Option {
+	return func(t *Tester) error {
+		t.duration = d
+		return nil
+	}
+}
+
+func WithRate(rps float64) Option {
+	return func(t *Tester) error {
+		t.rate = rps
+		return nil
+	}
+}
+
+func WithExpectedInterval(interval time.Duration) Option {
+	return func(t *Tester) error {
+		t.expectedInterval = interval
+		return nil
+	}
+}
+
+func WithRecorder(newRecorder func() (Recorder, error)) Option {
+	return func(t *Tester) error {
+		if newRecorder == nil {
+			return ErrValueCannotBeNil
+		}
+		t.newRecorder = newRecorder
+		return nil
+	}
+}
+
+func WithHDRRecorder(significantFigures int) Option {
+	return WithRecorder(func() (Recorder, error) {
+		return NewHDRRecorder(significantFigures)
+	})
+}
+
+func WithPercentiles(ps ...float64) Option {
+	return func(t *Tester) error {
+		if len(ps) == 0 {
+			return errors.New("no percentiles")
+		}
+		sorted := make([]float64, len(ps))
+		for i, p := range ps {
+			if p <= 0 || p > 100 {
+				return fmt.Errorf("%v is invalid percentile", p)
+			}
+			sorted[i] = p
+		}
+		sort.Float64s(sorted)
+		percentiles := []float64{}
+		for _, p := range sorted {
+			if !containsFloat(percentiles, p) {
+				percentiles = append(percentiles, p)
+			}
+		}
+		t.percentiles = percentiles
+		return nil
+	}
+}
+
+func ParsePercentiles(s string) ([]float64, error) {
+	ps := []float64{}
+	for _, field := range strings.Split(s, ",") {
+		p, err := strconv.ParseFloat(strings.TrimSpace(field), 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid percentile %q: %w", field, err)
+		}
+		ps = append(ps, p)
+	}
+	return ps, nil
+}
+
 func WithHTTPUserAgent(userAgent string) Option {
 	return func(t *Tester) error {
 		t.userAgent = userAgent
@@ -140,6 +521,47 @@ func WithHTTPUserAgent(userAgent string) Option {
 	}
 }
 
+func WithHTTPMethod(method string) Option {
+	return func(t *Tester) error {
+		t.method = method
+		return nil
+	}
+}
+
+func WithHTTPHeader(key, value string) Option {
+	return func(t *Tester) error {
+		t.header.Add(key, value)
+		return nil
+	}
+}
+
+func WithBody(body []byte) Option {
+	return func(t *Tester) error {
+		t.body = body
+		return nil
+	}
+}
+
+// WithBodyFile reads the request body from path, or from stdin if path is -.
+func WithBodyFile(path string) Option {
+	return func(t *Tester) error {
+		if path == "-" {
+			body, err := io.ReadAll(t.stdin)
+			if err != nil {
+				return err
+			}
+			t.body = body
+			return nil
+		}
+		body, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		t.body = body
+		return nil
+	}
+}
+
 func WithHTTPClient(client *http.Client) Option {
 	return func(t *Tester) error {
 		t.client = client
@@ -157,6 +579,16 @@ func WithStdout(w io.Writer) Option {
 	}
 }
 
+func WithStdin(r io.Reader) Option {
+	return func(t *Tester) error {
+		if r == nil {
+			return ErrValueCannotBeNil
+		}
+		t.stdin = r
+		return nil
+	}
+}
+
 func WithStderr(w io.Writer) Option {
 	return func(lg *Tester) error {
 		if w == nil {
@@ -210,6 +642,18 @@ func (t Tester) HTTPClient() *http.Client {
 	return t.client
 }
 
+func (t Tester) HTTPMethod() string {
+	return t.method
+}
+
+func (t Tester) HTTPHeader() http.Header {
+	return t.header
+}
+
+func (t Tester) Body() []byte {
+	return t.body
+}
+
 func (t Tester) StartTime() time.Time {
 	return t.startAt
 }
@@ -222,59 +666,238 @@ func (t Tester) Requests() int {
 	return t.requests
 }
 
+func (t Tester) Duration() time.Duration {
+	return t.duration
+}
+
+func (t Tester) Rate() float64 {
+	return t.rate
+}
+
+func (t Tester) ExpectedInterval() time.Duration {
+	return t.expectedInterval
+}
+
+func (t Tester) Percentiles() []float64 {
+	return t.percentiles
+}
+
+// DoRequest sends a request for every work item until the Work channel is
+// closed. Failures are recorded and never stop the worker.
 func (t *Tester) DoRequest() {
-	for range t.Work {
-		t.RecordRequest()
-		req, err := http.NewRequest(http.MethodGet, t.URL, nil)
-		if err != nil {
-			t.LogStdErr(err.Error())
-			t.RecordFailure()
+	t.work(nil)
+}
+
+// work is DoRequest for a worker that can also be stopped by closing stop.
+func (t *Tester) work(stop <-chan struct{}) {
+	worker := t.nextWorker()
+	renderer := t.newRenderer()
+	for {
+		var scheduledAt time.Time
+		select {
+		case <-stop:
 			return
+		case next, ok := <-t.Work:
+			if !ok {
+				return
+			}
+			scheduledAt = next
 		}
-		req.Header.Set("user-agent", t.HTTPUserAgent())
-		req.Header.Set("accept", "*/*")
-		startTime := time.Now()
-		resp, err := t.client.Do(req)
-		elapsedTime := time.Since(startTime)
+		e, ok := t.source.Next()
+		if !ok {
+			continue
+		}
+		err := t.doRequest(renderer, worker, e, scheduledAt)
 		if err != nil {
-			t.RecordFailure()
-			t.LogStdErr(err.Error())
-			return
+			t.LogFStdErr("%v\n", err)
+			t.checkErrorBudget()
 		}
-		t.TimeRecorder.RecordTime(float64(elapsedTime.Nanoseconds()) / 1000000.0)
-		if resp.StatusCode != http.StatusOK {
-			t.LogFStdErr("unexpected status code %d\n", resp.StatusCode)
-			t.RecordFailure()
-			return
+		t.checkThresholds()
+	}
+}
+
+// doRequest sends one request and records its outcome, returning the error
+// the request failed with, if any.
+func (t *Tester) doRequest(renderer *renderer, worker int, e Endpoint, scheduledAt time.Time) error {
+	r := t.send(renderer, e, scheduledAt)
+	r.worker = worker
+	return t.record(r)
+}
+
+// result is the outcome of one request. Without a response there is no
+// status code, latency or size to record.
+type result struct {
+	endpoint    Endpoint
+	worker      int
+	scheduledAt time.Time
+	startTime   time.Time
+	bodyDone    time.Time
+	timer       *phaseTimer
+	responded   bool
+	statusCode  int
+	sent        int64
+	received    int64
+	err         error
+	class       string
+}
+
+func (r result) elapsed() time.Duration {
+	return r.bodyDone.Sub(r.startTime)
+}
+
+// send sends one request and reads its whole response.
+func (t *Tester) send(renderer *renderer, e Endpoint, scheduledAt time.Time) result {
+	r := result{
+		endpoint:    e,
+		scheduledAt: scheduledAt,
+		timer:       &phaseTimer{},
+	}
+	req, err := renderer.newRequest(e, t.nextSeq())
+	if err != nil {
+		r.err, r.class = err, ErrorClassRequest
+		return r
+	}
+	req = req.WithContext(httptrace.WithClientTrace(req.Context(), r.timer.trace()))
+	r.startTime = time.Now()
+	resp, err := t.client.Do(req)
+	if err != nil {
+		r.err, r.class = err, ClassifyError(err)
+		return r
+	}
+	defer resp.Body.Close()
+	body := &countingReader{r: resp.Body}
+	resp.Body = io.NopCloser(body)
+	err = t.checkResponse(resp)
+	// Whatever the assertions left unread is still read, so latency covers
+	// the whole response and the connection can be reused.
+	_, drainErr := io.Copy(io.Discard, body)
+	if err == nil {
+		err = drainErr
+	}
+	r.bodyDone = time.Now()
+	r.responded = true
+	r.statusCode = resp.StatusCode
+	r.sent, r.received = req.ContentLength, body.n
+	if err != nil {
+		r.err, r.class = err, ClassifyError(err)
+	}
+	return r
+}
+
+// record adds the outcome of a request to the statistics of the run, of its
+// endpoint and of its stage, returning the error it failed with, if any.
+func (t *Tester) record(r result) error {
+	if t.resultLog != nil {
+		t.resultLog.results <- r.toResult()
+	}
+	t.recordInterval(r)
+	t.RecordRequest()
+	endpoint, err := t.endpointStatsFor(r.endpoint)
+	if err != nil {
+		t.RecordError(ErrorClassRequest, err)
+		return err
+	}
+	group := statsGroup{endpoint}
+	if stage := t.stageStatsFor(r.scheduledAt); stage != nil {
+		group = append(group, stage)
+	}
+	group.record(r)
+	if r.responded {
+		t.RecordStatusCode(r.statusCode)
+		t.TimeRecorder.RecordTime(milliseconds(r.elapsed()))
+		t.RecordCorrectedTime(r.scheduledAt, r.startTime, r.elapsed())
+		t.recordPhases(r.timer, r.bodyDone)
+		t.RecordBytes(r.sent, r.received)
+		t.recordThresholds(milliseconds(r.elapsed()))
+		if t.progress != nil {
+			t.progress.add(milliseconds(r.elapsed()))
+		}
+	}
+	if r.err != nil {
+		t.RecordError(r.class, r.err)
+		return r.err
+	}
+	t.RecordSuccess()
+	return nil
+}
+
+// contentType detects the media type of body, which http.DetectContentType
+// would report as text/plain if it is JSON.
+func contentType(body []byte) string {
+	if json.Valid(body) {
+		return "application/json"
+	}
+	return http.DetectContentType(body)
+}
+
+// countingReader counts the bytes read through it.
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// NewRequest builds a fresh request for the endpoint, so its body can be
+// sent as many times as needed. Headers given to the Tester apply to every
+// endpoint; the endpoint's own headers take precedence. Either can set the
+// Content-Type, which otherwise is detected from the body.
+func (t *Tester) NewRequest(e Endpoint) (*http.Request, error) {
+	req, err := http.NewRequest(e.Method, e.URL, strings.NewReader(e.Body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("user-agent", t.userAgent)
+	req.Header.Set("accept", "*/*")
+	if e.Body != "" {
+		req.Header.Set("content-type", contentType([]byte(e.Body)))
+	}
+	for key, values := range t.header {
+		if key == "Host" {
+			req.Host = values[0]
+			continue
+		}
+		req.Header[key] = values
+	}
+	for key, value := range e.Headers {
+		if http.CanonicalHeaderKey(key) == "Host" {
+			req.Host = value
+			continue
 		}
-		t.RecordSuccess()
+		req.Header.Set(key, value)
 	}
+	return req, nil
 }
 
 func (t *Tester) Run() error {
-	t.wg.Add(t.Concurrency)
-	go func() {
-		for x := 0; x < t.requests; x++ {
-			t.Work <- struct{}{}
+	var err error
+	if t.resultLogWriter != nil || t.resultLogPath != "" {
+		t.resultLog, err = t.startResultLog()
+		if err != nil {
+			return err
 		}
-		close(t.Work)
-	}()
-	t.startAt = time.Now()
-	go func() {
-		for x := 0; x < t.Concurrency; x++ {
-			go func() {
-				t.DoRequest()
-				t.wg.Done()
-			}()
+	}
+	if len(t.results) > 0 {
+		t.loadResults()
+	} else {
+		t.run()
+	}
+	if t.resultLog != nil {
+		err = t.resultLog.stop()
+		if err != nil {
+			return err
 		}
-	}()
-	t.wg.Wait()
-	t.EndAt = time.Since(t.startAt)
-	err := t.SetMetrics()
-	if err != nil {
+	}
+	err = t.SetMetrics()
+	if err != nil && !errors.Is(err, ErrTimeNotRecorded) {
 		return err
 	}
-	if t.Graphs {
+	timed := err == nil
+	if t.Graphs && timed {
 		err = t.Boxplot()
 		if err != nil {
 			return err
@@ -283,31 +906,213 @@ func (t *Tester) Run() error {
 		if err != nil {
 			return err
 		}
+		if len(t.stats.Phases) > 0 {
+			err = t.PhasesGraph()
+			if err != nil {
+				return err
+			}
+		}
+		if len(t.stats.TimeSeries) > 0 {
+			err = t.LatencyGraph()
+			if err != nil {
+				return err
+			}
+		}
 	}
-	if t.ExportStats {
-		file, err := os.Create(fmt.Sprintf("%s/%s", t.OutputPath, "statsfile.txt"))
+	if t.timeSeries != nil {
+		err = t.exportTimeSeries()
 		if err != nil {
 			return err
 		}
-		defer file.Close()
-		err = WriteStatsFile(file, t.Stats())
+	}
+	if t.ExportStats {
+		err = t.exportStats()
 		if err != nil {
 			return err
 		}
 	}
-	t.LogFStdOut("The benchmark of %s site took %v\n", t.URL, t.EndAt.Round(time.Millisecond))
+	if t.URL != "" {
+		t.LogFStdOut("The benchmark of %s site took %v\n", t.URL, t.EndAt.Round(time.Millisecond))
+	} else {
+		t.LogFStdOut("The benchmark of %d endpoints took %v\n", len(t.endpointNames), t.EndAt.Round(time.Millisecond))
+	}
 	t.LogFStdOut("Requests: %d Success: %d Failures: %d\n", t.stats.Requests, t.stats.Successes, t.stats.Failures)
-	t.LogFStdOut("P50: %.3fms P90: %.3fms P99: %.3fms\n", t.stats.P50, t.stats.P90, t.stats.P99)
+	t.LogFStdOut("Throughput: %.2f req/s %s/s Sent: %s Received: %s\n", t.stats.RequestsPerSecond(),
+		formatBytes(t.stats.BytesPerSecond()), formatBytes(float64(t.stats.RequestBytes)), formatBytes(float64(t.stats.ResponseBytes)))
+	if t.rate > 0 {
+		t.LogFStdOut("Rate: %.2f/s Late: %d Dropped: %d\n", t.rate, t.stats.Late, t.stats.Dropped)
+	}
+	if timed {
+		t.LogFStdOut("Min: %.3fms Mean: %.3fms Max: %.3fms StdDev: %.3fms\n", t.stats.Min, t.stats.Mean, t.stats.Max, t.stats.StdDev)
+		t.LogFStdOut("%s\n", formatPercentiles(t.stats.Percentiles))
+	}
+	if t.CorrectedTimeRecorder.Count() > 0 {
+		t.LogFStdOut("Corrected %s\n", formatPercentiles(t.stats.CorrectedPercentiles))
+	}
+	for _, phase := range t.stats.Phases {
+		t.LogFStdOut("Phase %s: Mean: %.3fms %s\n", phase.Phase, phase.Mean, formatPercentiles(phase.Percentiles))
+	}
+	if len(t.stats.StatusCodes) > 0 {
+		t.LogFStdOut("Status codes: %s\n", formatStatusCodes(t.stats.StatusCodes))
+	}
+	if len(t.stats.Errors) > 0 {
+		t.LogFStdOut("Errors: %s\n", formatCounts(t.stats.Errors))
+	}
+	if len(t.stats.AssertionFailures) > 0 {
+		t.LogFStdOut("Assertion failures: %s\n", formatCounts(t.stats.AssertionFailures))
+	}
+	for _, e := range t.stats.Endpoints {
+		t.LogFStdOut("%s: Requests: %d Success: %d Failures: %d %s\n", e.Endpoint, e.Requests, e.Successes, e.Failures, formatPercentiles(e.Percentiles))
+	}
+	if w := t.stats.Warmup; w != nil && t.warmupReport {
+		t.LogFStdOut("Warm-up: Requests: %d Success: %d Failures: %d Took: %v %s\n", w.Requests, w.Successes, w.Failures,
+			w.Duration.Round(time.Millisecond), formatPercentiles(w.Percentiles))
+	}
+	for i, s := range t.stats.Stages {
+		t.LogFStdOut("Stage %d (%v to %d workers): Requests: %d Success: %d Failures: %d Throughput: %.2f req/s %s\n", s.Stage,
+			t.stages[i].Duration, t.stages[i].Target, s.Requests, s.Successes, s.Failures, s.RequestsPerSecond(), formatPercentiles(s.Percentiles))
+	}
+	failed := []string{}
+	for _, r := range CheckThresholds(t.stats, t.thresholds) {
+		if !timed && r.Threshold.latency() {
+			r.Value, r.Pass = math.NaN(), false
+		}
+		t.LogFStdOut("%s\n", r)
+		if !r.Pass {
+			failed = append(failed, r.Threshold.Expr)
+		}
+	}
+	if reason := t.AbortReason(); reason != "" {
+		t.LogFStdOut("Aborted: %s\n", reason)
+		return fmt.Errorf("%w: %s", ErrAborted, reason)
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("%w: %s", ErrThresholdsFailed, strings.Join(failed, ", "))
+	}
+	if !timed {
+		return ErrTimeNotRecorded
+	}
 	return nil
 }
 
+// run sends requests until the benchmark is over, after warming up.
+func (t *Tester) run() {
+	t.warmUp()
+	t.startAt = time.Now()
+	stopProgress := t.startProgress()
+	if t.rate > 0 {
+		t.wg.Add(1)
+		go func() {
+			t.Schedule()
+			t.wg.Done()
+		}()
+	} else {
+		feed := func() {
+			defer close(t.Work)
+			if t.duration > 0 {
+				deadline := time.NewTimer(t.duration)
+				defer deadline.Stop()
+				for {
+					select {
+					case t.Work <- time.Now():
+					case <-deadline.C:
+						return
+					case <-t.aborted:
+						return
+					}
+				}
+			}
+			for x := 0; x < t.requests; x++ {
+				select {
+				case t.Work <- time.Now():
+				case <-t.aborted:
+					return
+				}
+			}
+		}
+		if len(t.replay) > 0 {
+			feed = t.feedReplay
+		}
+		go feed()
+		if len(t.stages) > 0 {
+			t.wg.Add(1)
+			go t.controlWorkers()
+		} else {
+			t.wg.Add(t.Concurrency)
+			go func() {
+				for x := 0; x < t.Concurrency; x++ {
+					go func() {
+						t.DoRequest()
+						t.wg.Done()
+					}()
+				}
+			}()
+		}
+	}
+	t.wg.Wait()
+	t.EndAt = time.Since(t.startAt)
+	stopProgress()
+}
+
+// Schedule sends requests at a fixed arrival rate regardless of response
+// times, spawning workers on demand up to Concurrency. A request that finds
+// every worker busy is late, or dropped if none frees up before the next slot.
+func (t *Tester) Schedule() {
+	defer close(t.Work)
+	interval := time.Duration(float64(time.Second) / t.rate)
+	deadline := t.startAt.Add(t.duration)
+	workers := 0
+	next := t.startAt
+	for x := 0; ; x++ {
+		if t.duration > 0 && !next.Before(deadline) {
+			return
+		}
+		if t.duration == 0 && x >= t.requests {
+			return
+		}
+		wait := time.NewTimer(time.Until(next))
+		select {
+		case <-wait.C:
+		case <-t.aborted:
+			wait.Stop()
+			return
+		}
+		select {
+		case t.Work <- next:
+		default:
+			if workers < t.Concurrency {
+				workers++
+				t.wg.Add(1)
+				go func() {
+					t.DoRequest()
+					t.wg.Done()
+				}()
+				t.Work <- next
+			} else {
+				timer := time.NewTimer(time.Until(next.Add(interval)))
+				select {
+				case t.Work <- next:
+					t.RecordLate()
+				case <-timer.C:
+					t.RecordDropped()
+				case <-t.aborted:
+					timer.Stop()
+					return
+				}
+				timer.Stop()
+			}
+		}
+		next = next.Add(interval)
+	}
+}
+
 func (t Tester) Boxplot() error {
 	p := plot.New()
 	p.Title.Text = "Latency boxplot"
 	p.Y.Label.Text = "latency (ms)"
 	p.X.Label.Text = t.URL
 	w := vg.Points(20)
-	box, err := plotter.NewBoxPlot(w, 0, plotter.Values(t.TimeRecorder.ExecutionsTime))
+	box, err := plotter.NewBoxPlot(w, 0, plotter.Values(t.TimeRecorder.Values()))
 	if err != nil {
 		return err
 	}
@@ -324,7 +1129,7 @@ func (t Tester) Histogram() error {
 	p.Title.Text = "Latency Histogram"
 	p.Y.Label.Text = "n reqs"
 	p.X.Label.Text = "latency (ms)"
-	hist, err := plotter.NewHist(plotter.Values(t.TimeRecorder.ExecutionsTime), 50)
+	hist, err := plotter.NewHist(plotter.Values(t.TimeRecorder.Values()), 50)
 	if err != nil {
 		return err
 	}
@@ -342,6 +1147,36 @@ func (t *Tester) RecordRequest() {
 	t.stats.Requests++
 }
 
+// RecordError records a failed request, counted by its class and, for
+// responses that fail assertions, by the reason.
+func (t *Tester) RecordError(class string, err error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.stats.recordError(class, err)
+}
+
+// RecordBytes records the body bytes of a request and of its response.
+func (t *Tester) RecordBytes(sent, received int64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.stats.RequestBytes += sent
+	t.stats.ResponseBytes += received
+}
+
+func (t *Tester) RecordStatusCode(code int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.stats.recordStatusCode(code)
+}
+
+func (t *Tester) nextSeq() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	seq := t.seq
+	t.seq++
+	return seq
+}
+
 func (t *Tester) RecordSuccess() {
 	t.mu.Lock()
 	defer t.mu.Unlock()
@@ -354,166 +1189,477 @@ func (t *Tester) RecordFailure() {
 	t.stats.Failures++
 }
 
-func (t Tester) LogStdOut(msg string) {
+// RecordCorrectedTime records latency corrected for coordinated omission. With
+// a target rate it is measured from the intended send time; with an expected
+// interval, synthetic samples are back-filled for the requests a stalled
+// worker would otherwise have sent, as HdrHistogram does.
+func (t *Tester) RecordCorrectedTime(scheduledAt, startTime time.Time, elapsedTime time.Duration) {
+	switch {
+	case t.rate > 0:
+		t.CorrectedTimeRecorder.RecordTime(milliseconds(startTime.Sub(scheduledAt) + elapsedTime))
+	case t.expectedInterval > 0:
+		t.CorrectedTimeRecorder.RecordTime(milliseconds(elapsedTime))
+		for missing := elapsedTime - t.expectedInterval; missing >= t.expectedInterval; missing -= t.expectedInterval {
+			t.CorrectedTimeRecorder.RecordTime(milliseconds(missing))
+		}
+	}
+}
+
+func (t *Tester) RecordLate() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.stats.Late++
+}
+
+func (t *Tester) nextWorker() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	worker := t.workers
+	t.workers++
+	return worker
+}
+
+func (t *Tester) RecordDropped() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.stats.Dropped++
+}
+
+type repeatedFlag []string
+
+func (h *repeatedFlag) String() string {
+	return strings.Join(*h, ", ")
+}
+
+func (h *repeatedFlag) Set(value string) error {
+	*h = append(*h, value)
+	return nil
+}
+
+func parseHeader(h string) (string, string, error) {
+	fields := strings.SplitN(h, ":", 2)
+	if len(fields) != 2 || strings.TrimSpace(fields[0]) == "" {
+		return "", "", fmt.Errorf("invalid header %q, want 'Key: Value'", h)
+	}
+	return strings.TrimSpace(fields[0]), strings.TrimSpace(fields[1]), nil
+}
+
+func (t *Tester) LogStdOut(msg string) {
 	fmt.Fprint(t.stdout, msg)
 }
 
-func (t Tester) LogStdErr(msg string) {
+func (t *Tester) LogStdErr(msg string) {
 	fmt.Fprint(t.stderr, msg)
 }
 
-func (t Tester) LogFStdOut(msg string, opts ...interface{}) {
+func (t *Tester) LogFStdOut(msg string, opts ...interface{}) {
 	fmt.Fprintf(t.stdout, msg, opts...)
 }
 
-func (t Tester) LogFStdErr(msg string, opts ...interface{}) {
+func (t *Tester) LogFStdErr(msg string, opts ...interface{}) {
 	fmt.Fprintf(t.stderr, msg, opts...)
 }
 
+// SetMetrics fills in the stats of the run. If no response was timed, the
+// latency metrics are left out and ErrTimeNotRecorded is returned once the
+// rest are set.
 func (t *Tester) SetMetrics() error {
-	times := t.TimeRecorder.ExecutionsTime
-	if len(times) < 1 {
-		return ErrTimeNotRecorded
+	timed := t.TimeRecorder.Count() > 0
+	if timed {
+		t.setRecorderMetrics(&t.stats, t.TimeRecorder)
 	}
-	sort.Slice(times, func(i, j int) bool {
-		return times[i] < times[j]
-	})
-	p50Idx := int(math.Round(float64(len(times))*0.5)) - 1
-	t.stats.P50 = times[p50Idx]
-	p90Idx := int(math.Round(float64(len(times))*0.9)) - 1
-	t.stats.P90 = times[p90Idx]
-	p99Idx := int(math.Round(float64(len(times))*0.99)) - 1
-	t.stats.P99 = times[p99Idx]
-
-	nreq := 0.0
-	totalTime := 0.0
-	for _, v := range times {
-		nreq++
-		totalTime += v
+	if t.CorrectedTimeRecorder.Count() > 0 {
+		t.stats.CorrectedP50 = t.CorrectedTimeRecorder.Percentile(0.5)
+		t.stats.CorrectedP90 = t.CorrectedTimeRecorder.Percentile(0.9)
+		t.stats.CorrectedP99 = t.CorrectedTimeRecorder.Percentile(0.99)
+		t.stats.CorrectedPercentiles = percentiles(t.CorrectedTimeRecorder, t.percentiles)
 	}
 	t.stats.URL = t.URL
-	t.stats.Mean = totalTime / nreq
+	t.stats.Duration = t.EndAt
+	t.stats.ConfiguredDuration = t.duration
+	if t.statsSamples {
+		t.stats.Samples = samples(t.TimeRecorder)
+	}
+	t.stats.Endpoints = t.endpointMetrics()
+	t.stats.Phases = t.phaseMetrics()
+	t.stats.Stages = t.stageMetrics()
+	t.stats.Warmup = t.warmupMetrics()
+	t.stats.TimeSeries = t.timeSeriesMetrics()
+	if !timed {
+		return ErrTimeNotRecorded
+	}
 	return nil
 }
 
+func (t *Tester) setRecorderMetrics(stats *Stats, r Recorder) {
+	stats.P50 = r.Percentile(0.5)
+	stats.P90 = r.Percentile(0.9)
+	stats.P99 = r.Percentile(0.99)
+	stats.Percentiles = percentiles(r, t.percentiles)
+	stats.Mean = r.Mean()
+	stats.Min = r.Min()
+	stats.Max = r.Max()
+	stats.StdDev = r.StdDev()
+}
+
+func percentiles(r Recorder, ps []float64) []Percentile {
+	values := make([]Percentile, len(ps))
+	for i, p := range ps {
+		values[i] = Percentile{
+			P:     p,
+			Value: r.Percentile(p / 100),
+		}
+	}
+	return values
+}
+
+func formatPercentiles(ps []Percentile) string {
+	fields := make([]string, len(ps))
+	for i, p := range ps {
+		fields[i] = fmt.Sprintf("%s: %.3fms", p.Label(), p.Value)
+	}
+	return strings.Join(fields, " ")
+}
+
+func formatCounts(counts map[string]int) string {
+	keys := make([]string, 0, len(counts))
+	for k := range counts {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	fields := make([]string, len(keys))
+	for i, k := range keys {
+		fields[i] = fmt.Sprintf("%s: %d", k, counts[k])
+	}
+	return strings.Join(fields, " ")
+}
+
+// formatBytes formats a number of bytes with a binary unit prefix.
+func formatBytes(b float64) string {
+	units := []string{"B", "KiB", "MiB", "GiB", "TiB"}
+	i := 0
+	for b >= 1024 && i < len(units)-1 {
+		b /= 1024
+		i++
+	}
+	if i == 0 {
+		return fmt.Sprintf("%.0f%s", b, units[i])
+	}
+	return fmt.Sprintf("%.2f%s", b, units[i])
+}
+
+func formatStatusCodes(codes map[int]int) string {
+	keys := make([]int, 0, len(codes))
+	for k := range codes {
+		keys = append(keys, k)
+	}
+	sort.Ints(keys)
+	fields := make([]string, len(keys))
+	for i, k := range keys {
+		fields[i] = fmt.Sprintf("%d: %d", k, codes[k])
+	}
+	return strings.Join(fields, " ")
+}
+
+func milliseconds(d time.Duration) float64 {
+	return float64(d.Nanoseconds()) / 1000000.0
+}
+
+// Percentile is the latency in milliseconds at percentile P (0 to 100).
+type Percentile struct {
+	P     float64
+	Value float64
+}
+
+func (p Percentile) Label() string {
+	return "P" + strconv.FormatFloat(p.P, 'f', -1, 64)
+}
+
 type Stats struct {
-	URL       string
-	Mean      float64
-	P50       float64
-	P90       float64
-	P99       float64
-	Failures  int
-	Requests  int
-	Successes int
+	URL                  string
+	Endpoint             string
+	Stage                int
+	Mean                 float64
+	Min                  float64
+	Max                  float64
+	StdDev               float64
+	P50                  float64
+	P90                  float64
+	P99                  float64
+	CorrectedP50         float64
+	CorrectedP90         float64
+	CorrectedP99         float64
+	Percentiles          []Percentile
+	CorrectedPercentiles []Percentile
+	Failures             int
+	Errors               map[string]int
+	StatusCodes          map[int]int
+	AssertionFailures    map[string]int
+	Requests             int
+	Successes            int
+	RequestBytes         int64
+	ResponseBytes        int64
+	Late                 int
+	Dropped              int
+	Duration             time.Duration
+	ConfiguredDuration   time.Duration
+	Endpoints            []Stats
+	Stages               []Stats
+	Phases               []PhaseStats
+	Warmup               *Stats
+	TimeSeries           []IntervalStats
+	Samples              []float64
+}
+
+// Percentile returns the recorded value for percentile p (0 to 100), if any.
+func (s Stats) Percentile(p float64) (float64, bool) {
+	for _, v := range s.Percentiles {
+		if v.P == p {
+			return v.Value, true
+		}
+	}
+	return 0, false
+}
+
+func (s *Stats) recordError(class string, err error) {
+	s.Failures++
+	if s.Errors == nil {
+		s.Errors = map[string]int{}
+	}
+	s.Errors[class]++
+	assertionErr := &AssertionError{}
+	if errors.As(err, &assertionErr) {
+		if s.AssertionFailures == nil {
+			s.AssertionFailures = map[string]int{}
+		}
+		s.AssertionFailures[assertionErr.Reason]++
+	}
+}
+
+func (s *Stats) recordStatusCode(code int) {
+	if s.StatusCodes == nil {
+		s.StatusCodes = map[int]int{}
+	}
+	s.StatusCodes[code]++
+}
+
+// TotalBytes is the body bytes sent and received.
+func (s Stats) TotalBytes() int64 {
+	return s.RequestBytes + s.ResponseBytes
+}
+
+func (s Stats) BytesPerSecond() float64 {
+	if s.Duration <= 0 {
+		return 0
+	}
+	return float64(s.TotalBytes()) / s.Duration.Seconds()
+}
+
+func (s Stats) RequestsPerSecond() float64 {
+	if s.Duration <= 0 {
+		return 0
+	}
+	return float64(s.Requests) / s.Duration.Seconds()
 }
 
 type StatsDelta struct {
-	P50       float64
-	P90       float64
-	P99       float64
-	Requests  int
-	Failures  int
-	Successes int
+	Mean              float64
+	Min               float64
+	Max               float64
+	StdDev            float64
+	P50               float64
+	P90               float64
+	P99               float64
+	Percentiles       []Percentile
+	Requests          int
+	Failures          int
+	Successes         int
+	RequestBytes      int64
+	ResponseBytes     int64
+	Errors            map[string]int
+	StatusCodes       map[int]int
+	Duration          time.Duration
+	RequestsPerSecond float64
+	BytesPerSecond    float64
 }
 
+// Recorder collects request latencies in milliseconds. Percentile takes a
+// quantile between 0 and 1. Values returns the samples used for graphs.
+type Recorder interface {
+	RecordTime(executionTime float64)
+	Count() int
+	Min() float64
+	Max() float64
+	Mean() float64
+	StdDev() float64
+	Percentile(p float64) float64
+	Values() []float64
+}
+
+// TimeRecorder keeps every sample, so its statistics are exact but its
+// memory grows with the number of requests.
 type TimeRecorder struct {
 	mu             *sync.Mutex
 	ExecutionsTime []float64
 }
 
+func NewTimeRecorder() *TimeRecorder {
+	return &TimeRecorder{
+		ExecutionsTime: []float64{},
+		mu:             &sync.Mutex{},
+	}
+}
+
 func (t *TimeRecorder) RecordTime(executionTime float64) {
 	t.mu.Lock()
 	defer t.mu.Unlock()
 	t.ExecutionsTime = append(t.ExecutionsTime, executionTime)
 }
 
-type Option func(*Tester) error
+func (t *TimeRecorder) Count() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return len(t.ExecutionsTime)
+}
 
-func CompareStats(stats1, stats2 Stats) StatsDelta {
-	statsDelta := StatsDelta{
-		P50:       stats2.P50 - stats1.P50,
-		P90:       stats2.P90 - stats1.P90,
-		P99:       stats2.P99 - stats1.P99,
-		Requests:  stats2.Requests - stats1.Requests,
-		Successes: stats2.Successes - stats1.Successes,
-		Failures:  stats2.Failures - stats1.Failures,
+func (t *TimeRecorder) Min() float64 {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if len(t.ExecutionsTime) == 0 {
+		return 0
 	}
-	return statsDelta
+	t.sort()
+	return t.ExecutionsTime[0]
 }
 
-func CompareStatsFiles(path1, path2 string) (StatsDelta, error) {
-	f1, err := os.Open(path1)
-	if err != nil {
-		return StatsDelta{}, err
+func (t *TimeRecorder) Max() float64 {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if len(t.ExecutionsTime) == 0 {
+		return 0
 	}
-	defer f1.Close()
-	ReadStatsFile(f1)
-	f2, err := os.Open(path1)
-	if err != nil {
-		return StatsDelta{}, err
+	t.sort()
+	return t.ExecutionsTime[len(t.ExecutionsTime)-1]
+}
+
+func (t *TimeRecorder) Mean() float64 {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.mean()
+}
+
+func (t *TimeRecorder) mean() float64 {
+	if len(t.ExecutionsTime) == 0 {
+		return 0
 	}
-	defer f2.Close()
-	return StatsDelta{}, nil
+	totalTime := 0.0
+	for _, v := range t.ExecutionsTime {
+		totalTime += v
+	}
+	return totalTime / float64(len(t.ExecutionsTime))
 }
 
-func ReadStatsFile(r io.Reader) ([]Stats, error) {
-	scanner := bufio.NewScanner(r)
-	stats := []Stats{}
-	for scanner.Scan() {
-		pos := strings.Split(scanner.Text(), ",")
-		url := pos[0]
-		dataRequests := pos[1]
-		requests, err := strconv.Atoi(dataRequests)
-		if err != nil {
-			return nil, err
-		}
-		dataSuccesses := pos[2]
-		successes, err := strconv.Atoi(dataSuccesses)
-		if err != nil {
-			return nil, err
-		}
-		dataFailures := pos[3]
-		failures, err := strconv.Atoi(dataFailures)
-		if err != nil {
-			return nil, err
-		}
-		dataP50 := pos[4]
-		p50, err := strconv.ParseFloat(dataP50, 64)
-		if err != nil {
-			return nil, err
-		}
-		dataP90 := pos[5]
-		p90, err := strconv.ParseFloat(dataP90, 64)
-		if err != nil {
-			return nil, err
-		}
-		dataP99 := pos[6]
-		p99, err := strconv.ParseFloat(dataP99, 64)
-		if err != nil {
-			return nil, err
+func (t *TimeRecorder) StdDev() float64 {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if len(t.ExecutionsTime) == 0 {
+		return 0
+	}
+	mean := t.mean()
+	variance := 0.0
+	for _, v := range t.ExecutionsTime {
+		variance += (v - mean) * (v - mean)
+	}
+	return math.Sqrt(variance / float64(len(t.ExecutionsTime)))
+}
+
+// Percentile interpolates linearly between the closest ranks, so small
+// sample counts still produce a sensible value for high percentiles.
+func (t *TimeRecorder) Percentile(p float64) float64 {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	times := t.ExecutionsTime
+	if len(times) == 0 {
+		return 0
+	}
+	t.sort()
+	rank := p * float64(len(times)-1)
+	lower := int(math.Floor(rank))
+	if lower >= len(times)-1 {
+		return times[len(times)-1]
+	}
+	return times[lower] + (rank-float64(lower))*(times[lower+1]-times[lower])
+}
+
+func (t *TimeRecorder) sort() {
+	if !sort.Float64sAreSorted(t.ExecutionsTime) {
+		sort.Float64s(t.ExecutionsTime)
+	}
+}
+
+func (t *TimeRecorder) Values() []float64 {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.ExecutionsTime
+}
+
+type Option func(*Tester) error
+
+func CompareStats(stats1, stats2 Stats) StatsDelta {
+	statsDelta := StatsDelta{
+		Mean:              stats2.Mean - stats1.Mean,
+		Min:               stats2.Min - stats1.Min,
+		Max:               stats2.Max - stats1.Max,
+		StdDev:            stats2.StdDev - stats1.StdDev,
+		P50:               stats2.P50 - stats1.P50,
+		P90:               stats2.P90 - stats1.P90,
+		P99:               stats2.P99 - stats1.P99,
+		Requests:          stats2.Requests - stats1.Requests,
+		Successes:         stats2.Successes - stats1.Successes,
+		Failures:          stats2.Failures - stats1.Failures,
+		Duration:          stats2.Duration - stats1.Duration,
+		RequestsPerSecond: stats2.RequestsPerSecond() - stats1.RequestsPerSecond(),
+		RequestBytes:      stats2.RequestBytes - stats1.RequestBytes,
+		ResponseBytes:     stats2.ResponseBytes - stats1.ResponseBytes,
+		BytesPerSecond:    stats2.BytesPerSecond() - stats1.BytesPerSecond(),
+	}
+	for _, p := range stats1.Percentiles {
+		v2, ok := stats2.Percentile(p.P)
+		if !ok {
+			continue
 		}
-		stats = append(stats, Stats{
-			Failures:  failures,
-			P50:       p50,
-			P90:       p90,
-			P99:       p99,
-			Requests:  requests,
-			Successes: successes,
-			URL:       url,
+		statsDelta.Percentiles = append(statsDelta.Percentiles, Percentile{
+			P:     p.P,
+			Value: v2 - p.Value,
 		})
 	}
-	if err := scanner.Err(); err != nil {
-		return nil, err
+	for class := range stats1.Errors {
+		statsDelta.Errors = addCountDelta(statsDelta.Errors, class, stats2.Errors[class]-stats1.Errors[class])
+	}
+	for class := range stats2.Errors {
+		statsDelta.Errors = addCountDelta(statsDelta.Errors, class, stats2.Errors[class]-stats1.Errors[class])
+	}
+	for code := range stats1.StatusCodes {
+		statsDelta.StatusCodes = addStatusCodeDelta(statsDelta.StatusCodes, code, stats2.StatusCodes[code]-stats1.StatusCodes[code])
+	}
+	for code := range stats2.StatusCodes {
+		statsDelta.StatusCodes = addStatusCodeDelta(statsDelta.StatusCodes, code, stats2.StatusCodes[code]-stats1.StatusCodes[code])
+	}
+	return statsDelta
+}
+
+func addCountDelta(deltas map[string]int, key string, delta int) map[string]int {
+	if deltas == nil {
+		deltas = map[string]int{}
 	}
-	return stats, nil
+	deltas[key] = delta
+	return deltas
 }
 
-func WriteStatsFile(w io.Writer, stats Stats) error {
-	_, err := fmt.Fprintf(w, "%s,%d,%d,%d,%.3f,%.3f,%.3f",
-		stats.URL, stats.Requests, stats.Successes, stats.Failures, stats.P50, stats.P90, stats.P99,
-	)
-	if err != nil {
-		return err
+func addStatusCodeDelta(deltas map[int]int, code int, delta int) map[int]int {
+	if deltas == nil {
+		deltas = map[int]int{}
 	}
-	return nil
+	deltas[code] = delta
+	return deltas
 }
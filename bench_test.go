@@ -5,10 +5,13 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"math"
+	"net"
 	"net/http"
 	"net/http/httptest"
 	"os"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 
@@ -360,11 +363,11 @@ func TestTimeRecorderCalledMultipleTimesSetCorrectPercentilesAndReturnsNoError(t
 	if stats.P50 != 8 {
 		t.Errorf("want 50th percentile request time of 8ms, got %v", stats.P50)
 	}
-	if stats.P90 != 11 {
-		t.Errorf("want 90th percentile request time of 11ms, got %v", stats.P90)
+	if math.Abs(stats.P90-11.8) > 1e-9 {
+		t.Errorf("want 90th percentile request time of 11.8ms, got %v", stats.P90)
 	}
-	if stats.P99 != 13 {
-		t.Errorf("want 99th percentile request time of 13ms, got %v", stats.P99)
+	if math.Abs(stats.P99-12.88) > 1e-9 {
+		t.Errorf("want 99th percentile request time of 12.88ms, got %v", stats.P99)
 	}
 }
 
@@ -671,7 +674,7 @@ func TestConfiguredExportStatsFlagGenerateStatsFile(t *testing.T) {
 	if err != nil {
 		t.Fatal(err)
 	}
-	filePath := fmt.Sprintf("%s/%s", tester.OutputPath, "statsfile.txt")
+	filePath := fmt.Sprintf("%s/%s", tester.OutputPath, "stats.json")
 	_, err = os.Stat(filePath)
 	if err != nil {
 		t.Errorf("want file %q to exist", filePath)
@@ -706,7 +709,7 @@ func TestUnconfiguredExportStatsFlagDoesNotGenerateStatsFile(t *testing.T) {
 	if err != nil {
 		t.Fatal(err)
 	}
-	filePath := fmt.Sprintf("%s/%s", tester.OutputPath, "statsfile.txt")
+	filePath := fmt.Sprintf("%s/%s", tester.OutputPath, "stats.json")
 	_, err = os.Stat(filePath)
 	if err == nil {
 		t.Errorf("want file %q to not exist. Error found: %v", filePath, err)
@@ -936,9 +939,901 @@ func TestWriteStatsFilePopulatesCorrectStatsFile(t *testing.T) {
 	if err != nil {
 		t.Fatal(err)
 	}
-	want := `http://fake.url,20,18,2,100.123,150.000,198.465`
-	got := output.String()
+	if !strings.HasPrefix(output.String(), "{\n  \"Version\": 1,") {
+		t.Errorf("want a versioned JSON stats file, got %q", output.String())
+	}
+	want := []bench.Stats{stats}
+	got, err := bench.ReadStatsFile(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !cmp.Equal(want, got) {
+		t.Error(cmp.Diff(want, got))
+	}
+}
+
+func TestNewTesterWithDurationIsConfiguredForDuration(t *testing.T) {
+	t.Parallel()
+	tester, err := bench.NewTester(
+		bench.WithURL("http://fake.url"),
+		bench.WithDuration(30*time.Second),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := 30 * time.Second
+	got := tester.Duration()
+	if want != got {
+		t.Errorf("want tester configured for %v, got %v", want, got)
+	}
+}
+
+func TestNewTesterWithInvalidDurationReturnsError(t *testing.T) {
+	t.Parallel()
+	_, err := bench.NewTester(
+		bench.WithURL("http://fake.url"),
+		bench.WithDuration(-time.Second),
+	)
+	if err == nil {
+		t.Fatal("want error for invalid duration (-1s)")
+	}
+}
+
+func TestFromArgsDurationFlagConfiguresDuration(t *testing.T) {
+	t.Parallel()
+	args := []string{"run", "-d", "30s", "-u", "http://fake.url"}
+	tester, err := bench.NewTester(
+		bench.WithStderr(io.Discard),
+		bench.FromArgs(args),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := 30 * time.Second
+	got := tester.Duration()
+	if want != got {
+		t.Errorf("duration: want %v, got %v", want, got)
+	}
+}
+
+func TestRunWithDurationSendsRequestsUntilDeadline(t *testing.T) {
+	t.Parallel()
+	server := httptest.NewTLSServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		time.Sleep(10 * time.Millisecond)
+		fmt.Fprintf(rw, "HelloWorld")
+	}))
+	tester, err := bench.NewTester(
+		bench.WithURL(server.URL),
+		bench.WithHTTPClient(server.Client()),
+		bench.WithConcurrency(2),
+		bench.WithDuration(200*time.Millisecond),
+		bench.WithStdout(io.Discard),
+		bench.WithStderr(io.Discard),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = tester.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stats := tester.Stats()
+	if stats.Requests < 2 {
+		t.Errorf("want more than one request made, got %d", stats.Requests)
+	}
+	if stats.Requests != stats.Successes+stats.Failures {
+		t.Error("want total requests to be the sum of successes + failures")
+	}
+	if stats.ConfiguredDuration != 200*time.Millisecond {
+		t.Errorf("want configured duration of 200ms, got %v", stats.ConfiguredDuration)
+	}
+	if stats.Duration < stats.ConfiguredDuration {
+		t.Errorf("want actual duration of at least %v, got %v", stats.ConfiguredDuration, stats.Duration)
+	}
+}
+
+func TestCompareStatsNormalisesRequestsPerSecondByDuration(t *testing.T) {
+	t.Parallel()
+	stats1 := bench.Stats{
+		Requests: 100,
+		Duration: 10 * time.Second,
+	}
+	stats2 := bench.Stats{
+		Requests: 600,
+		Duration: 30 * time.Second,
+	}
+	got := bench.CompareStats(stats1, stats2)
+	want := bench.StatsDelta{
+		Requests:          500,
+		Duration:          20 * time.Second,
+		RequestsPerSecond: 10,
+	}
+	if !cmp.Equal(want, got) {
+		t.Error(cmp.Diff(want, got))
+	}
+}
+
+func TestNewTesterWithRateIsConfiguredForRate(t *testing.T) {
+	t.Parallel()
+	tester, err := bench.NewTester(
+		bench.WithURL("http://fake.url"),
+		bench.WithRate(100),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := 100.0
+	got := tester.Rate()
+	if want != got {
+		t.Errorf("want tester configured for rate %v, got %v", want, got)
+	}
+}
+
+func TestNewTesterWithInvalidRateReturnsError(t *testing.T) {
+	t.Parallel()
+	_, err := bench.NewTester(
+		bench.WithURL("http://fake.url"),
+		bench.WithRate(-1),
+	)
+	if err == nil {
+		t.Fatal("want error for invalid rate (-1)")
+	}
+}
+
+func TestFromArgsRateFlagConfiguresRate(t *testing.T) {
+	t.Parallel()
+	args := []string{"run", "-rate", "250", "-u", "http://fake.url"}
+	tester, err := bench.NewTester(
+		bench.WithStderr(io.Discard),
+		bench.FromArgs(args),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := 250.0
+	got := tester.Rate()
+	if want != got {
+		t.Errorf("rate: want %v, got %v", want, got)
+	}
+}
+
+func TestRunWithRateSendsRequestsAtFixedArrivalRate(t *testing.T) {
+	t.Parallel()
+	server := httptest.NewTLSServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(rw, "HelloWorld")
+	}))
+	tester, err := bench.NewTester(
+		bench.WithURL(server.URL),
+		bench.WithHTTPClient(server.Client()),
+		bench.WithConcurrency(5),
+		bench.WithRate(50),
+		bench.WithRequests(10),
+		bench.WithStdout(io.Discard),
+		bench.WithStderr(io.Discard),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = tester.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stats := tester.Stats()
+	if stats.Requests+stats.Dropped != 10 {
+		t.Errorf("want 10 requests scheduled, got %d sent and %d dropped", stats.Requests, stats.Dropped)
+	}
+	if tester.EndAt < 180*time.Millisecond {
+		t.Errorf("want 10 requests at 50/s to take at least 180ms, got %v", tester.EndAt)
+	}
+}
+
+func TestRunWithRateRecordsDroppedRequestsWhenWorkersAreBusy(t *testing.T) {
+	t.Parallel()
+	server := httptest.NewTLSServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		time.Sleep(50 * time.Millisecond)
+		fmt.Fprintf(rw, "HelloWorld")
+	}))
+	tester, err := bench.NewTester(
+		bench.WithURL(server.URL),
+		bench.WithHTTPClient(server.Client()),
+		bench.WithRate(100),
+		bench.WithRequests(10),
+		bench.WithStdout(io.Discard),
+		bench.WithStderr(io.Discard),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = tester.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stats := tester.Stats()
+	if stats.Dropped == 0 {
+		t.Error("want dropped requests when the only worker is busy")
+	}
+	if stats.Requests+stats.Dropped != 10 {
+		t.Errorf("want 10 requests scheduled, got %d sent and %d dropped", stats.Requests, stats.Dropped)
+	}
+}
+
+func TestFromArgsExpectedIntervalFlagConfiguresExpectedInterval(t *testing.T) {
+	t.Parallel()
+	args := []string{"run", "-expected-interval", "10ms", "-u", "http://fake.url"}
+	tester, err := bench.NewTester(
+		bench.WithStderr(io.Discard),
+		bench.FromArgs(args),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := 10 * time.Millisecond
+	got := tester.ExpectedInterval()
+	if want != got {
+		t.Errorf("expected interval: want %v, got %v", want, got)
+	}
+}
+
+func TestRecordCorrectedTimeWithExpectedIntervalBackFillsMissingSamples(t *testing.T) {
+	t.Parallel()
+	tester, err := bench.NewTester(
+		bench.WithURL("http://fake.url"),
+		bench.WithExpectedInterval(10*time.Millisecond),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	tester.TimeRecorder.RecordTime(35)
+	tester.RecordCorrectedTime(now, now, 35*time.Millisecond)
+	want := []float64{35, 25, 15}
+	got := tester.CorrectedTimeRecorder.Values()
+	if !cmp.Equal(want, got) {
+		t.Fatal(cmp.Diff(want, got))
+	}
+	err = tester.SetMetrics()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stats := tester.Stats()
+	if stats.P50 != 35 {
+		t.Errorf("want raw 50th percentile of 35ms, got %v", stats.P50)
+	}
+	if stats.CorrectedP50 != 25 {
+		t.Errorf("want corrected 50th percentile of 25ms, got %v", stats.CorrectedP50)
+	}
+}
+
+func TestRecordCorrectedTimeWithRateMeasuresFromIntendedSendTime(t *testing.T) {
+	t.Parallel()
+	tester, err := bench.NewTester(
+		bench.WithURL("http://fake.url"),
+		bench.WithRate(10),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	scheduledAt := time.Now()
+	startTime := scheduledAt.Add(40 * time.Millisecond)
+	tester.RecordCorrectedTime(scheduledAt, startTime, 10*time.Millisecond)
+	want := []float64{50}
+	got := tester.CorrectedTimeRecorder.Values()
+	if !cmp.Equal(want, got) {
+		t.Error(cmp.Diff(want, got))
+	}
+}
+
+func TestRecordCorrectedTimeWithoutRateOrExpectedIntervalRecordsNothing(t *testing.T) {
+	t.Parallel()
+	tester, err := bench.NewTester(
+		bench.WithURL("http://fake.url"),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	tester.RecordCorrectedTime(now, now, 35*time.Millisecond)
+	if tester.CorrectedTimeRecorder.Count() != 0 {
+		t.Errorf("want no corrected samples, got %v", tester.CorrectedTimeRecorder.Values())
+	}
+}
+
+func TestNewTesterByDefaultIsConfiguredForDefaultPercentiles(t *testing.T) {
+	t.Parallel()
+	tester, err := bench.NewTester(
+		bench.WithURL("http://fake.url"),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := bench.DefaultPercentiles
+	got := tester.Percentiles()
+	if !cmp.Equal(want, got) {
+		t.Error(cmp.Diff(want, got))
+	}
+}
+
+func TestFromArgsPercentilesFlagConfiguresSortedPercentiles(t *testing.T) {
+	t.Parallel()
+	args := []string{"run", "-p", "99.9,50, 75,95", "-u", "http://fake.url"}
+	tester, err := bench.NewTester(
+		bench.WithStderr(io.Discard),
+		bench.FromArgs(args),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []float64{50, 75, 95, 99.9}
+	got := tester.Percentiles()
+	if !cmp.Equal(want, got) {
+		t.Error(cmp.Diff(want, got))
+	}
+}
+
+func TestNewTesterWithPercentilesDropsDuplicates(t *testing.T) {
+	t.Parallel()
+	tester, err := bench.NewTester(
+		bench.WithURL("http://fake.url"),
+		bench.WithPercentiles(99, 50, 99, 50, 75),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []float64{50, 75, 99}
+	got := tester.Percentiles()
+	if !cmp.Equal(want, got) {
+		t.Error(cmp.Diff(want, got))
+	}
+}
+
+func TestNewTesterWithInvalidPercentilesReturnsError(t *testing.T) {
+	t.Parallel()
+	inputs := [][]float64{
+		{},
+		{0},
+		{50, 100.1},
+		{-1},
+	}
+	for _, ps := range inputs {
+		_, err := bench.NewTester(
+			bench.WithURL("http://fake.url"),
+			bench.WithPercentiles(ps...),
+		)
+		if err == nil {
+			t.Errorf("want error for invalid percentiles %v", ps)
+		}
+	}
+}
+
+func TestFromArgsInvalidPercentilesFlagReturnsError(t *testing.T) {
+	t.Parallel()
+	_, err := bench.NewTester(
+		bench.WithStderr(io.Discard),
+		bench.FromArgs([]string{"run", "-p", "50,bogus", "-u", "http://fake.url"}),
+	)
+	if err == nil {
+		t.Error("want error for invalid percentile \"bogus\"")
+	}
+}
+
+func TestSetMetricsReportsConfiguredPercentilesAndSummaryStatistics(t *testing.T) {
+	t.Parallel()
+	tester, err := bench.NewTester(
+		bench.WithURL("http://fake.url"),
+		bench.WithPercentiles(25, 50, 75, 100),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, v := range []float64{2, 4, 4, 4, 5, 5, 7, 9} {
+		tester.TimeRecorder.RecordTime(v)
+	}
+	err = tester.SetMetrics()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stats := tester.Stats()
+	want := []bench.Percentile{
+		{P: 25, Value: 4},
+		{P: 50, Value: 4.5},
+		{P: 75, Value: 5.5},
+		{P: 100, Value: 9},
+	}
+	if !cmp.Equal(want, stats.Percentiles) {
+		t.Error(cmp.Diff(want, stats.Percentiles))
+	}
+	if stats.Min != 2 {
+		t.Errorf("want min of 2ms, got %v", stats.Min)
+	}
+	if stats.Max != 9 {
+		t.Errorf("want max of 9ms, got %v", stats.Max)
+	}
+	if stats.StdDev != 2 {
+		t.Errorf("want standard deviation of 2ms, got %v", stats.StdDev)
+	}
+}
+
+func TestWriteStatsFileAndReadStatsFileRoundTripPercentiles(t *testing.T) {
+	t.Parallel()
+	want := bench.Stats{
+		URL:       "http://fake.url",
+		Requests:  20,
+		Successes: 20,
+		P50:       100.5,
+		P90:       150,
+		P99:       198.25,
+		Mean:      101,
+		Min:       50,
+		Max:       200,
+		StdDev:    12.5,
+		Percentiles: []bench.Percentile{
+			{P: 50, Value: 100.5},
+			{P: 99.9, Value: 199.75},
+		},
+	}
+	output := &bytes.Buffer{}
+	err := bench.WriteStatsFile(output, want)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := bench.ReadStatsFile(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !cmp.Equal([]bench.Stats{want}, got) {
+		t.Error(cmp.Diff([]bench.Stats{want}, got))
+	}
+}
+
+func TestCompareStatsComparesPercentilesPresentInBothStats(t *testing.T) {
+	t.Parallel()
+	stats1 := bench.Stats{
+		Min: 1,
+		Max: 10,
+		Percentiles: []bench.Percentile{
+			{P: 75, Value: 5},
+			{P: 99.9, Value: 9},
+		},
+	}
+	stats2 := bench.Stats{
+		Min: 2,
+		Max: 8,
+		Percentiles: []bench.Percentile{
+			{P: 75, Value: 6},
+			{P: 95, Value: 7},
+		},
+	}
+	got := bench.CompareStats(stats1, stats2)
+	want := bench.StatsDelta{
+		Min: 1,
+		Max: -2,
+		Percentiles: []bench.Percentile{
+			{P: 75, Value: 1},
+		},
+	}
 	if !cmp.Equal(want, got) {
 		t.Error(cmp.Diff(want, got))
 	}
 }
+
+func TestNewTesterByDefaultUsesHTTPMethodGet(t *testing.T) {
+	t.Parallel()
+	tester, err := bench.NewTester(
+		bench.WithURL("http://fake.url"),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := http.MethodGet
+	got := tester.HTTPMethod()
+	if want != got {
+		t.Errorf("method: want %q, got %q", want, got)
+	}
+}
+
+func TestNewTesterWithInvalidHTTPMethodReturnsError(t *testing.T) {
+	t.Parallel()
+	_, err := bench.NewTester(
+		bench.WithURL("http://fake.url"),
+		bench.WithHTTPMethod("BOGUS METHOD"),
+	)
+	if err == nil {
+		t.Error("want error for invalid method")
+	}
+}
+
+func TestFromArgsMethodHeadersAndBodyFlagsConfigureRequest(t *testing.T) {
+	t.Parallel()
+	args := []string{
+		"run",
+		"-m", http.MethodPost,
+		"-H", "Authorization: Bearer x",
+		"-H", "X-Trace: a",
+		"-H", "X-Trace: b",
+		"-b", `{"hello":"world"}`,
+		"-u", "http://fake.url",
+	}
+	tester, err := bench.NewTester(
+		bench.WithStderr(io.Discard),
+		bench.FromArgs(args),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tester.HTTPMethod() != http.MethodPost {
+		t.Errorf("method: want %q, got %q", http.MethodPost, tester.HTTPMethod())
+	}
+	wantHeader := http.Header{
+		"Authorization": {"Bearer x"},
+		"X-Trace":       {"a", "b"},
+	}
+	if !cmp.Equal(wantHeader, tester.HTTPHeader()) {
+		t.Error(cmp.Diff(wantHeader, tester.HTTPHeader()))
+	}
+	wantBody := `{"hello":"world"}`
+	if wantBody != string(tester.Body()) {
+		t.Errorf("body: want %q, got %q", wantBody, tester.Body())
+	}
+}
+
+func TestFromArgsInvalidHeaderFlagReturnsError(t *testing.T) {
+	t.Parallel()
+	_, err := bench.NewTester(
+		bench.WithStderr(io.Discard),
+		bench.FromArgs([]string{"run", "-H", "no colon", "-u", "http://fake.url"}),
+	)
+	if err == nil {
+		t.Error("want error for invalid header")
+	}
+}
+
+func TestFromArgsBodyFileFlagReadsBodyFromFileOrStdin(t *testing.T) {
+	t.Parallel()
+	path := t.TempDir() + "/body.json"
+	err := os.WriteFile(path, []byte("from file"), 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tester, err := bench.NewTester(
+		bench.WithStderr(io.Discard),
+		bench.FromArgs([]string{"run", "-body-file", path, "-u", "http://fake.url"}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(tester.Body()) != "from file" {
+		t.Errorf("body: want %q, got %q", "from file", tester.Body())
+	}
+	tester, err = bench.NewTester(
+		bench.WithStderr(io.Discard),
+		bench.WithStdin(strings.NewReader("from stdin")),
+		bench.FromArgs([]string{"run", "-body-file", "-", "-u", "http://fake.url"}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(tester.Body()) != "from stdin" {
+		t.Errorf("body: want %q, got %q", "from stdin", tester.Body())
+	}
+}
+
+func TestFromArgsBodyAndBodyFileFlagsTogetherReturnError(t *testing.T) {
+	t.Parallel()
+	path := t.TempDir() + "/body.json"
+	err := os.WriteFile(path, []byte("from file"), 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = bench.NewTester(
+		bench.WithStderr(io.Discard),
+		bench.FromArgs([]string{"run", "-b", "inline", "-body-file", path, "-u", "http://fake.url"}),
+	)
+	if err == nil {
+		t.Error("want error for both -b and -body-file")
+	}
+}
+
+func TestRunSendsMethodHeadersAndBodyOnEveryRequest(t *testing.T) {
+	t.Parallel()
+	type received struct {
+		Method, Auth, ContentType, Body string
+		ContentLength                   int64
+	}
+	mu := sync.Mutex{}
+	requests := []received{}
+	server := httptest.NewTLSServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		requests = append(requests, received{
+			Method:        r.Method,
+			Auth:          r.Header.Get("Authorization"),
+			ContentType:   r.Header.Get("Content-Type"),
+			Body:          string(body),
+			ContentLength: r.ContentLength,
+		})
+		mu.Unlock()
+	}))
+	tester, err := bench.NewTester(
+		bench.WithURL(server.URL),
+		bench.WithHTTPClient(server.Client()),
+		bench.WithRequests(3),
+		bench.WithHTTPMethod(http.MethodPut),
+		bench.WithHTTPHeader("Authorization", "Bearer x"),
+		bench.WithHTTPHeader("Content-Type", "application/json"),
+		bench.WithBody([]byte(`{"id":1}`)),
+		bench.WithStdout(io.Discard),
+		bench.WithStderr(io.Discard),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = tester.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := received{
+		Method:        http.MethodPut,
+		Auth:          "Bearer x",
+		ContentType:   "application/json",
+		Body:          `{"id":1}`,
+		ContentLength: 8,
+	}
+	if len(requests) != 3 {
+		t.Fatalf("want 3 requests received, got %d", len(requests))
+	}
+	for _, got := range requests {
+		if !cmp.Equal(want, got) {
+			t.Error(cmp.Diff(want, got))
+		}
+	}
+}
+
+func TestRunSendsContentTypeDetectedFromBodyUnlessSet(t *testing.T) {
+	t.Parallel()
+	contentTypes := make(chan string, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		contentTypes <- r.Header.Get("Content-Type")
+	}))
+	defer server.Close()
+	for _, tc := range []struct {
+		body, header, want string
+	}{
+		{`{"id":1}`, "", "application/json"},
+		{`[1, 2]`, "", "application/json"},
+		{"id=1", "", "text/plain; charset=utf-8"},
+		{"<html><body></body></html>", "", "text/html; charset=utf-8"},
+		{`{"id":1}`, "text/plain", "text/plain"},
+	} {
+		opts := []bench.Option{
+			bench.WithURL(server.URL),
+			bench.WithRequests(1),
+			bench.WithHTTPMethod(http.MethodPost),
+			bench.WithBody([]byte(tc.body)),
+			bench.WithStdout(io.Discard),
+			bench.WithStderr(io.Discard),
+		}
+		if tc.header != "" {
+			opts = append(opts, bench.WithHTTPHeader("Content-Type", tc.header))
+		}
+		tester, err := bench.NewTester(opts...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = tester.Run()
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := <-contentTypes
+		if tc.want != got {
+			t.Errorf("body %q: want content type %q, got %q", tc.body, tc.want, got)
+		}
+	}
+}
+
+func TestRunKeepsSendingRequestsAfterFailures(t *testing.T) {
+	t.Parallel()
+	var mu sync.Mutex
+	calls := 0
+	server := httptest.NewTLSServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		calls++
+		n := calls
+		mu.Unlock()
+		if n <= 3 {
+			rw.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	tester, err := bench.NewTester(
+		bench.WithURL(server.URL),
+		bench.WithRequests(10),
+		bench.WithConcurrency(1),
+		bench.WithHTTPClient(server.Client()),
+		bench.WithStdout(io.Discard),
+		bench.WithStderr(io.Discard),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = tester.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stats := tester.Stats()
+	if stats.Requests != 10 || stats.Failures != 3 || stats.Successes != 7 {
+		t.Errorf("want 10 requests, 3 failures and 7 successes, got %d, %d and %d", stats.Requests, stats.Failures, stats.Successes)
+	}
+}
+
+func TestRunKeepsSendingRequestsAfterClientErrors(t *testing.T) {
+	t.Parallel()
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {}))
+	URL := server.URL
+	server.Close()
+	tester, err := bench.NewTester(
+		bench.WithURL(URL),
+		bench.WithRequests(5),
+		bench.WithConcurrency(1),
+		bench.WithHTTPClient(&http.Client{}),
+		bench.WithStdout(io.Discard),
+		bench.WithStderr(io.Discard),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = tester.Run()
+	if !errors.Is(err, bench.ErrTimeNotRecorded) {
+		t.Fatalf("want ErrTimeNotRecorded, got %v", err)
+	}
+	stats := tester.Stats()
+	if stats.Requests != 5 || stats.Failures != 5 {
+		t.Errorf("want 5 requests and 5 failures, got %d and %d", stats.Requests, stats.Failures)
+	}
+}
+
+func TestRunDrainsResponseBodiesToReuseConnections(t *testing.T) {
+	t.Parallel()
+	var mu sync.Mutex
+	connections := 0
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		rw.Write(bytes.Repeat([]byte("x"), 64*1024))
+	}))
+	server.Config.ConnState = func(c net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			mu.Lock()
+			connections++
+			mu.Unlock()
+		}
+	}
+	server.Start()
+	defer server.Close()
+	tester, err := bench.NewTester(
+		bench.WithURL(server.URL),
+		bench.WithRequests(10),
+		bench.WithConcurrency(1),
+		bench.WithHTTPClient(server.Client()),
+		bench.WithStdout(io.Discard),
+		bench.WithStderr(io.Discard),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = tester.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if connections != 1 {
+		t.Errorf("want 1 connection reused by every request, got %d", connections)
+	}
+}
+
+func TestRunLatencyIncludesReadingTheBody(t *testing.T) {
+	t.Parallel()
+	server := httptest.NewTLSServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		rw.Write([]byte("first part"))
+		rw.(http.Flusher).Flush()
+		time.Sleep(50 * time.Millisecond)
+		rw.Write([]byte("second part"))
+	}))
+	tester, err := bench.NewTester(
+		bench.WithURL(server.URL),
+		bench.WithHTTPClient(server.Client()),
+		bench.WithStdout(io.Discard),
+		bench.WithStderr(io.Discard),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = tester.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stats := tester.Stats()
+	if stats.Min < 50 {
+		t.Errorf("want latency to include the 50ms body transfer, got %.3fms", stats.Min)
+	}
+	body, ok := stats.Phase(bench.PhaseBody)
+	if !ok || body.Mean < 50 {
+		t.Errorf("want body phase of at least 50ms, got %+v", body)
+	}
+}
+
+func TestRunCountsRequestAndResponseBytes(t *testing.T) {
+	t.Parallel()
+	server := httptest.NewTLSServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		rw.Write(bytes.Repeat([]byte("x"), 10240))
+	}))
+	stdout := &bytes.Buffer{}
+	tester, err := bench.NewTester(
+		bench.WithURL(server.URL),
+		bench.WithRequests(3),
+		bench.WithHTTPMethod(http.MethodPost),
+		bench.WithBody([]byte("hello")),
+		bench.WithHTTPClient(server.Client()),
+		bench.WithStdout(stdout),
+		bench.WithStderr(io.Discard),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = tester.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stats := tester.Stats()
+	if stats.RequestBytes != 15 {
+		t.Errorf("want 15 request bytes, got %d", stats.RequestBytes)
+	}
+	if stats.ResponseBytes != 30720 {
+		t.Errorf("want 30720 response bytes, got %d", stats.ResponseBytes)
+	}
+	if stats.BytesPerSecond() <= 0 {
+		t.Errorf("want positive bytes per second, got %v", stats.BytesPerSecond())
+	}
+	if !strings.Contains(stdout.String(), "Sent: 15B Received: 30.00KiB\n") {
+		t.Errorf("want bytes in summary, got %q", stdout)
+	}
+}
+
+func TestStatsThroughput(t *testing.T) {
+	t.Parallel()
+	stats := bench.Stats{
+		Requests:      100,
+		RequestBytes:  1000,
+		ResponseBytes: 9000,
+		Duration:      2 * time.Second,
+	}
+	if stats.TotalBytes() != 10000 {
+		t.Errorf("want 10000 total bytes, got %d", stats.TotalBytes())
+	}
+	if stats.BytesPerSecond() != 5000 {
+		t.Errorf("want 5000 bytes per second, got %v", stats.BytesPerSecond())
+	}
+	if stats.RequestsPerSecond() != 50 {
+		t.Errorf("want 50 requests per second, got %v", stats.RequestsPerSecond())
+	}
+}
+
+func TestWriteStatsFileAndReadStatsFileRoundTripBytes(t *testing.T) {
+	t.Parallel()
+	want := bench.Stats{
+		URL:           "http://fake.url",
+		Requests:      10,
+		Successes:     10,
+		RequestBytes:  50,
+		ResponseBytes: 102400,
+	}
+	output := &bytes.Buffer{}
+	err := bench.WriteStatsFile(output, want)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := bench.ReadStatsFile(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !cmp.Equal([]bench.Stats{want}, got) {
+		t.Error(cmp.Diff([]bench.Stats{want}, got))
+	}
+}
@@ -1,20 +1,96 @@
 package main
 
 import (
+	"flag"
 	"fmt"
 	"os"
 
 	"github.com/thiagonache/bench"
 )
 
-var usage = "Please do not press this button again"
+var usage = `Usage: simplebenchcmp [flags] OLD_STATS_FILE NEW_STATS_FILE
+       simplebenchcmp [-graphs] [-o DIR] STATS_FILE... (three or more, for a trend)`
 
 func main() {
-	if len(os.Args) != 3 {
+	fs := flag.NewFlagSet("simplebenchcmp", flag.ExitOnError)
+	fs.Usage = func() {
 		fmt.Fprintln(os.Stderr, usage)
+		fs.PrintDefaults()
+	}
+	alpha := fs.Float64("alpha", bench.DefaultAlpha, "significance level below which latency changes are reported, for stats files with samples")
+	graphs := fs.Bool("graphs", false, "draw the trend of three or more stats files to trend.png and trend-errors.png")
+	outputPath := fs.String("o", ".", "directory to write the trend graphs to")
+	budget := bench.Budget{}
+	for _, f := range []struct {
+		name, usage string
+		limit       *float64
+	}{
+		{"max-p50-increase", "fail if P50 increases by more than this (e.g. 10%)", &budget.MaxP50Increase},
+		{"max-p90-increase", "fail if P90 increases by more than this (e.g. 10%)", &budget.MaxP90Increase},
+		{"max-p99-increase", "fail if P99 increases by more than this (e.g. 10%)", &budget.MaxP99Increase},
+		{"max-mean-increase", "fail if the mean latency increases by more than this (e.g. 10%)", &budget.MaxMeanIncrease},
+		{"max-error-rate", "fail if the error rate of the new run is over this (e.g. 1%)", &budget.MaxErrorRate},
+		{"max-rps-decrease", "fail if throughput decreases by more than this (e.g. 5%)", &budget.MaxRPSDecrease},
+	} {
+		limit := f.limit
+		fs.Func(f.name, f.usage, func(s string) error {
+			v, err := bench.ParseRate(s)
+			if err != nil {
+				return err
+			}
+			*limit = v
+			return nil
+		})
+	}
+	fs.Parse(os.Args[1:])
+	if fs.NArg() < 2 || *alpha <= 0 || *alpha >= 1 {
+		fs.Usage()
+		os.Exit(1)
+	}
+	if fs.NArg() > 2 {
+		if budget != (bench.Budget{}) {
+			fmt.Fprintln(os.Stderr, "budgets compare exactly two stats files")
+			os.Exit(1)
+		}
+		printTrend(fs.Args(), *graphs, *outputPath)
+		return
+	}
+	comparisons, err := bench.CompareStatsFilesByEntry(fs.Arg(0), fs.Arg(1))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
 		os.Exit(1)
 	}
-	err := bench.CompareStatsFiles(os.Args[1], os.Args[2])
+	err = bench.WriteComparisons(os.Stdout, comparisons, *alpha)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	if budget == (bench.Budget{}) {
+		return
+	}
+	fmt.Println()
+	err = bench.WriteViolations(os.Stdout, bench.CheckComparisons(comparisons, budget, *alpha))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func printTrend(paths []string, graphs bool, outputPath string) {
+	trend, err := bench.ReadTrend(paths...)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	err = bench.WriteTrend(os.Stdout, trend)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	if !graphs {
+		return
+	}
+	err = bench.TrendGraphs(outputPath, trend)
 	if err != nil {
 		fmt.Fprintln(os.Stderr, err)
 		os.Exit(1)
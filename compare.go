@@ -0,0 +1,264 @@
+package bench
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"text/tabwriter"
+	"time"
+)
+
+var ErrNoMatchingStats = errors.New("no stats match between the two files")
+
+// Comparison holds the stats of the same run or endpoint in two stats files
+// and the difference between them. Significance is set when both have
+// latency samples.
+type Comparison struct {
+	URL          string
+	Endpoint     string
+	Old          Stats
+	New          Stats
+	Delta        StatsDelta
+	Significance *Significance
+}
+
+// Significant reports whether the change in latency is significant at
+// alpha, which it always is without samples to test.
+func (c Comparison) Significant(alpha float64) bool {
+	return c.Significance == nil || c.Significance.PValue < alpha
+}
+
+type statsKey struct {
+	url, endpoint string
+}
+
+// MatchStats pairs the runs in stats1 and stats2 that have the same URL, and
+// within each pair the endpoints with the same name and URL, comparing them
+// in the order of stats1. A run is followed by its endpoints. Two files of a
+// single run each are compared whatever their URLs.
+func MatchStats(stats1, stats2 []Stats) ([]Comparison, error) {
+	pairs := matchStats(stats1, stats2)
+	if len(stats1) == 1 && len(stats2) == 1 {
+		pairs = [][2]Stats{{stats1[0], stats2[0]}}
+	}
+	comparisons := []Comparison{}
+	for _, pair := range pairs {
+		comparisons = append(comparisons, newComparison(pair[0], pair[1]))
+		for _, endpoints := range matchStats(pair[0].Endpoints, pair[1].Endpoints) {
+			comparisons = append(comparisons, newComparison(endpoints[0], endpoints[1]))
+		}
+	}
+	if len(comparisons) == 0 {
+		return nil, ErrNoMatchingStats
+	}
+	return comparisons, nil
+}
+
+func matchStats(stats1, stats2 []Stats) [][2]Stats {
+	byKey := map[statsKey]Stats{}
+	for _, s := range stats2 {
+		byKey[statsKey{s.URL, s.Endpoint}] = s
+	}
+	var pairs [][2]Stats
+	for _, s := range stats1 {
+		s2, ok := byKey[statsKey{s.URL, s.Endpoint}]
+		if !ok {
+			continue
+		}
+		pairs = append(pairs, [2]Stats{s, s2})
+	}
+	return pairs
+}
+
+func newComparison(stats1, stats2 Stats) Comparison {
+	c := Comparison{
+		URL:      stats1.URL,
+		Endpoint: stats1.Endpoint,
+		Old:      stats1,
+		New:      stats2,
+		Delta:    CompareStats(stats1, stats2),
+	}
+	if len(stats1.Samples) > 0 && len(stats2.Samples) > 0 {
+		s := MannWhitneyU(stats1.Samples, stats2.Samples)
+		c.Significance = &s
+	}
+	return c
+}
+
+// CompareStatsFiles reads two stats files and compares their first matching
+// run.
+func CompareStatsFiles(path1, path2 string) (StatsDelta, error) {
+	comparisons, err := CompareStatsFilesByEntry(path1, path2)
+	if err != nil {
+		return StatsDelta{}, err
+	}
+	return comparisons[0].Delta, nil
+}
+
+// CompareStatsFilesByEntry reads two stats files and compares every run and
+// endpoint they have in common.
+func CompareStatsFilesByEntry(path1, path2 string) ([]Comparison, error) {
+	f1, err := ReadStatsFilePath(path1)
+	if err != nil {
+		return nil, err
+	}
+	f2, err := ReadStatsFilePath(path2)
+	if err != nil {
+		return nil, err
+	}
+	comparisons, err := MatchStats(f1.Stats, f2.Stats)
+	if err != nil {
+		return nil, fmt.Errorf("comparing %s and %s: %w", path1, path2, err)
+	}
+	return comparisons, nil
+}
+
+// metric is one line of a comparison, with format rendering its old and new
+// values and their difference. Latency metrics are subject to the
+// comparison's significance test.
+type metric struct {
+	name     string
+	old, new float64
+	format   func(float64) string
+	latency  bool
+}
+
+func formatCount(v float64) string {
+	return strconv.FormatFloat(v, 'f', 0, 64)
+}
+
+func formatMilliseconds(v float64) string {
+	return strconv.FormatFloat(v, 'f', 3, 64) + "ms"
+}
+
+func formatRate(v float64) string {
+	return strconv.FormatFloat(v, 'f', 2, 64)
+}
+
+func formatSeconds(v float64) string {
+	return time.Duration(v * float64(time.Second)).String()
+}
+
+// metrics lists every metric of the comparison, leaving out corrected
+// percentiles and latency percentiles missing from either run.
+func (c Comparison) metrics() []metric {
+	from, to := c.Old, c.New
+	metrics := []metric{
+		{"requests", float64(from.Requests), float64(to.Requests), formatCount, false},
+		{"successes", float64(from.Successes), float64(to.Successes), formatCount, false},
+		{"failures", float64(from.Failures), float64(to.Failures), formatCount, false},
+		{"req/s", from.RequestsPerSecond(), to.RequestsPerSecond(), formatRate, false},
+		{"mean", from.Mean, to.Mean, formatMilliseconds, true},
+		{"min", from.Min, to.Min, formatMilliseconds, true},
+		{"max", from.Max, to.Max, formatMilliseconds, true},
+		{"stddev", from.StdDev, to.StdDev, formatMilliseconds, true},
+		{"P50", from.P50, to.P50, formatMilliseconds, true},
+		{"P90", from.P90, to.P90, formatMilliseconds, true},
+		{"P99", from.P99, to.P99, formatMilliseconds, true},
+	}
+	for _, p := range from.Percentiles {
+		if p.P == 50 || p.P == 90 || p.P == 99 {
+			continue
+		}
+		v, ok := to.Percentile(p.P)
+		if !ok {
+			continue
+		}
+		metrics = append(metrics, metric{p.Label(), p.Value, v, formatMilliseconds, true})
+	}
+	if len(from.CorrectedPercentiles) > 0 && len(to.CorrectedPercentiles) > 0 {
+		metrics = append(metrics,
+			metric{"corrected P50", from.CorrectedP50, to.CorrectedP50, formatMilliseconds, false},
+			metric{"corrected P90", from.CorrectedP90, to.CorrectedP90, formatMilliseconds, false},
+			metric{"corrected P99", from.CorrectedP99, to.CorrectedP99, formatMilliseconds, false},
+		)
+	}
+	metrics = append(metrics,
+		metric{"request bytes", float64(from.RequestBytes), float64(to.RequestBytes), formatCount, false},
+		metric{"response bytes", float64(from.ResponseBytes), float64(to.ResponseBytes), formatCount, false},
+		metric{"bytes/s", from.BytesPerSecond(), to.BytesPerSecond(), formatRate, false},
+		metric{"duration", from.Duration.Seconds(), to.Duration.Seconds(), formatSeconds, false},
+	)
+	classes := map[string]int{}
+	for class := range from.Errors {
+		classes[class]++
+	}
+	for class := range to.Errors {
+		classes[class]++
+	}
+	for _, class := range sortedKeys(classes) {
+		metrics = append(metrics, metric{"error:" + class, float64(from.Errors[class]), float64(to.Errors[class]), formatCount, false})
+	}
+	codes := []int{}
+	for code := range from.StatusCodes {
+		codes = append(codes, code)
+	}
+	for code := range to.StatusCodes {
+		if _, ok := from.StatusCodes[code]; !ok {
+			codes = append(codes, code)
+		}
+	}
+	sort.Ints(codes)
+	for _, code := range codes {
+		metrics = append(metrics, metric{fmt.Sprintf("status:%d", code), float64(from.StatusCodes[code]), float64(to.StatusCodes[code]), formatCount, false})
+	}
+	return metrics
+}
+
+// percentChange formats the change from one value to another relative to
+// the first.
+func percentChange(from, to float64) string {
+	if from == to {
+		return "0.00%"
+	}
+	if from == 0 {
+		return "n/a"
+	}
+	return fmt.Sprintf("%+.2f%%", (to-from)/from*100)
+}
+
+func formatDelta(m metric) string {
+	delta := m.new - m.old
+	sign := "+"
+	if delta < 0 {
+		sign = "-"
+		delta = -delta
+	}
+	return sign + m.format(delta)
+}
+
+// entryTitle names a run by its URL and an endpoint by its name and URL.
+func entryTitle(url, endpoint string) string {
+	if endpoint == "" {
+		return url
+	}
+	return fmt.Sprintf("%s (%s)", endpoint, url)
+}
+
+// WriteComparisons writes a table per comparison with the old and new value
+// of every metric, the difference between them and the change in percent.
+// When the comparison has a significance test, latency changes that are not
+// significant at alpha show as "~", and each latency change is followed by
+// its p-value and the number of samples, like benchstat.
+func WriteComparisons(w io.Writer, comparisons []Comparison, alpha float64) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	for i, c := range comparisons {
+		if i > 0 {
+			fmt.Fprintln(tw)
+		}
+		fmt.Fprintf(tw, "%s\nmetric\told\tnew\tdelta\t%%\n", entryTitle(c.URL, c.Endpoint))
+		for _, m := range c.metrics() {
+			change := percentChange(m.old, m.new)
+			if m.latency && c.Significance != nil {
+				if !c.Significant(alpha) {
+					change = "~"
+				}
+				change += fmt.Sprintf(" (p=%.3f n=%d+%d)", c.Significance.PValue, c.Significance.N1, c.Significance.N2)
+			}
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", m.name, m.format(m.old), m.format(m.new), formatDelta(m), change)
+		}
+	}
+	return tw.Flush()
+}
@@ -0,0 +1,226 @@
+package bench_test
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/thiagonache/bench"
+)
+
+func TestMatchStats_MatchesRunsByURLAndEndpointsByName(t *testing.T) {
+	t.Parallel()
+	stats1 := []bench.Stats{
+		{
+			URL:      "http://a.url",
+			Requests: 10,
+			Endpoints: []bench.Stats{
+				{Endpoint: "GET /x", URL: "http://a.url/x", Requests: 4},
+				{Endpoint: "GET /y", URL: "http://a.url/y", Requests: 6},
+			},
+		},
+		{URL: "http://b.url", Requests: 5},
+		{URL: "http://gone.url", Requests: 1},
+	}
+	stats2 := []bench.Stats{
+		{URL: "http://b.url", Requests: 15},
+		{
+			URL:      "http://a.url",
+			Requests: 20,
+			Endpoints: []bench.Stats{
+				{Endpoint: "GET /y", URL: "http://a.url/y", Requests: 16},
+				{Endpoint: "GET /z", URL: "http://a.url/z", Requests: 4},
+			},
+		},
+	}
+	comparisons, err := bench.MatchStats(stats1, stats2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	type match struct {
+		URL, Endpoint string
+		Requests      int
+	}
+	want := []match{
+		{"http://a.url", "", 10},
+		{"http://a.url/y", "GET /y", 10},
+		{"http://b.url", "", 10},
+	}
+	got := []match{}
+	for _, c := range comparisons {
+		got = append(got, match{c.URL, c.Endpoint, c.Delta.Requests})
+	}
+	if !cmp.Equal(want, got) {
+		t.Error(cmp.Diff(want, got))
+	}
+}
+
+func TestMatchStats_ComparesSingleRunsWithDifferentURLs(t *testing.T) {
+	t.Parallel()
+	comparisons, err := bench.MatchStats(
+		[]bench.Stats{{URL: "http://staging.url", P50: 10}},
+		[]bench.Stats{{URL: "http://production.url", P50: 12}},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(comparisons) != 1 || comparisons[0].Delta.P50 != 2 {
+		t.Errorf("want single comparison with P50 delta of 2, got %+v", comparisons)
+	}
+}
+
+func TestMatchStats_ErrorsIfNothingMatches(t *testing.T) {
+	t.Parallel()
+	_, err := bench.MatchStats(
+		[]bench.Stats{{URL: "http://a.url"}, {URL: "http://b.url"}},
+		[]bench.Stats{{URL: "http://c.url"}},
+	)
+	if !errors.Is(err, bench.ErrNoMatchingStats) {
+		t.Errorf("want ErrNoMatchingStats, got %v", err)
+	}
+}
+
+func TestCompareStatsFilesByEntry_ReadsFilesInAnyFormat(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	legacy := dir + "/old.txt"
+	err := os.WriteFile(legacy, []byte("http://fake.url,20,18,2,20,30,100\n"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	current := dir + "/new.csv"
+	f, err := os.Create(current)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = bench.WriteStatsFileCSV(f, bench.StatsFile{
+		Version: bench.StatsFileVersion,
+		Stats:   []bench.Stats{{URL: "http://fake.url", Requests: 40, Successes: 39, Failures: 1, P50: 5, P90: 33, P99: 99}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+	comparisons, err := bench.CompareStatsFilesByEntry(legacy, current)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := bench.StatsDelta{
+		Failures:  -1,
+		P50:       -15,
+		P90:       3,
+		P99:       -1,
+		Requests:  20,
+		Successes: 21,
+	}
+	got := comparisons[0].Delta
+	if !cmp.Equal(want, got) {
+		t.Error(cmp.Diff(want, got))
+	}
+}
+
+func TestWriteComparisons_PrintsOldNewDeltaAndPercentForEveryMetric(t *testing.T) {
+	t.Parallel()
+	comparisons, err := bench.MatchStats(
+		[]bench.Stats{{
+			URL:         "http://fake.url",
+			Requests:    100,
+			Successes:   99,
+			Failures:    1,
+			P50:         10,
+			P90:         20,
+			P99:         40,
+			Mean:        12,
+			Min:         1,
+			Max:         50,
+			StdDev:      4,
+			Percentiles: []bench.Percentile{{P: 50, Value: 10}, {P: 75, Value: 15}},
+			Errors:      map[string]int{bench.ErrorClassTimeout: 1},
+			StatusCodes: map[int]int{200: 99},
+			Duration:    10 * time.Second,
+		}},
+		[]bench.Stats{{
+			URL:         "http://fake.url",
+			Requests:    200,
+			Successes:   200,
+			P50:         8,
+			P90:         20,
+			P99:         50,
+			Mean:        9,
+			Min:         1,
+			Max:         60,
+			StdDev:      3,
+			Percentiles: []bench.Percentile{{P: 50, Value: 8}, {P: 75, Value: 12}},
+			StatusCodes: map[int]int{200: 199, 201: 1},
+			Duration:    10 * time.Second,
+		}},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	buf := &bytes.Buffer{}
+	err = bench.WriteComparisons(buf, comparisons, bench.DefaultAlpha)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `http://fake.url
+metric          old       new       delta      %
+requests        100       200       +100       +100.00%
+successes       99        200       +101       +102.02%
+failures        1         0         -1         -100.00%
+req/s           10.00     20.00     +10.00     +100.00%
+mean            12.000ms  9.000ms   -3.000ms   -25.00%
+min             1.000ms   1.000ms   +0.000ms   0.00%
+max             50.000ms  60.000ms  +10.000ms  +20.00%
+stddev          4.000ms   3.000ms   -1.000ms   -25.00%
+P50             10.000ms  8.000ms   -2.000ms   -20.00%
+P90             20.000ms  20.000ms  +0.000ms   0.00%
+P99             40.000ms  50.000ms  +10.000ms  +25.00%
+P75             15.000ms  12.000ms  -3.000ms   -20.00%
+request bytes   0         0         +0         0.00%
+response bytes  0         0         +0         0.00%
+bytes/s         0.00      0.00      +0.00      0.00%
+duration        10s       10s       +0s        0.00%
+error:timeout   1         0         -1         -100.00%
+status:200      99        199       +100       +101.01%
+status:201      0         1         +1         n/a
+`
+	got := buf.String()
+	if want != got {
+		t.Error(cmp.Diff(want, got))
+	}
+}
+
+func TestWriteComparisons_MarksLatencyChangesThatAreNotSignificant(t *testing.T) {
+	t.Parallel()
+	comparisons, err := bench.MatchStats(
+		[]bench.Stats{{URL: "http://fake.url", Requests: 5, P50: 3, Samples: []float64{1, 2, 3, 4, 5}}},
+		[]bench.Stats{{URL: "http://fake.url", Requests: 5, P50: 8, Samples: []float64{6, 7, 8, 9, 10}}},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range []struct {
+		alpha float64
+		want  string
+	}{
+		{bench.DefaultAlpha, "P50             3.000ms  8.000ms  +5.000ms  +166.67% (p=0.012 n=5+5)\n"},
+		{0.01, "P50             3.000ms  8.000ms  +5.000ms  ~ (p=0.012 n=5+5)\n"},
+	} {
+		buf := &bytes.Buffer{}
+		err = bench.WriteComparisons(buf, comparisons, tc.alpha)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(buf.String(), tc.want) {
+			t.Errorf("alpha %v: want line %q, got:\n%s", tc.alpha, tc.want, buf)
+		}
+		if !strings.Contains(buf.String(), "requests        5        5        +0        0.00%\n") {
+			t.Errorf("alpha %v: want no p-value for request count, got:\n%s", tc.alpha, buf)
+		}
+	}
+}
@@ -0,0 +1,88 @@
+package bench
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+const (
+	ErrorClassDNS       = "dns"
+	ErrorClassConnect   = "connect refused"
+	ErrorClassTLS       = "tls"
+	ErrorClassTimeout   = "timeout"
+	ErrorClassReset     = "connection reset"
+	ErrorClassStatus    = "status"
+	ErrorClassAssertion = "assertion"
+	ErrorClassRequest   = "request"
+	ErrorClassOther     = "other"
+)
+
+// ClassifyError returns the class of a failed request: one of the
+// ErrorClass constants or, for unaccepted responses, "status" followed by
+// the status code.
+func ClassifyError(err error) string {
+	assertionErr := &AssertionError{}
+	if errors.As(err, &assertionErr) {
+		if assertionErr.Reason == ReasonStatus {
+			return StatusClass(assertionErr.StatusCode)
+		}
+		return ErrorClassAssertion
+	}
+	dnsErr := &net.DNSError{}
+	if errors.As(err, &dnsErr) {
+		return ErrorClassDNS
+	}
+	if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, syscall.ETIMEDOUT) {
+		return ErrorClassTimeout
+	}
+	netErr := net.Error(nil)
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return ErrorClassTimeout
+	}
+	if errors.Is(err, syscall.ECONNREFUSED) {
+		return ErrorClassConnect
+	}
+	if isTLSError(err) {
+		return ErrorClassTLS
+	}
+	if errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.EPIPE) ||
+		errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return ErrorClassReset
+	}
+	return ErrorClassOther
+}
+
+// StatusClass is the error class of a response with an unaccepted status
+// code.
+func StatusClass(code int) string {
+	return ErrorClassStatus + " " + strconv.Itoa(code)
+}
+
+func isTLSError(err error) bool {
+	recordErr := tls.RecordHeaderError{}
+	if errors.As(err, &recordErr) {
+		return true
+	}
+	unknownAuthorityErr := x509.UnknownAuthorityError{}
+	if errors.As(err, &unknownAuthorityErr) {
+		return true
+	}
+	hostnameErr := x509.HostnameError{}
+	if errors.As(err, &hostnameErr) {
+		return true
+	}
+	invalidErr := x509.CertificateInvalidError{}
+	if errors.As(err, &invalidErr) {
+		return true
+	}
+	// Handshake alerts are not exported, so they are recognised by their
+	// message.
+	return strings.Contains(err.Error(), "tls: ")
+}
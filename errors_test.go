@@ -0,0 +1,232 @@
+package bench_test
+
+import (
+	"bytes"
+	"context"
+	"crypto/x509"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/thiagonache/bench"
+)
+
+func TestClassifyError(t *testing.T) {
+	t.Parallel()
+	opErr := func(err error) error {
+		return &url.Error{Op: "Get", URL: "https://example.com", Err: &net.OpError{Op: "dial", Net: "tcp", Err: err}}
+	}
+	tests := map[string]error{
+		bench.ErrorClassDNS:       opErr(&net.DNSError{Err: "no such host", Name: "example.invalid", IsNotFound: true}),
+		bench.ErrorClassConnect:   opErr(os.NewSyscallError("connect", syscall.ECONNREFUSED)),
+		bench.ErrorClassTLS:       &url.Error{Op: "Get", URL: "https://example.com", Err: x509.UnknownAuthorityError{}},
+		bench.ErrorClassTimeout:   &url.Error{Op: "Get", URL: "https://example.com", Err: context.DeadlineExceeded},
+		bench.ErrorClassReset:     opErr(os.NewSyscallError("read", syscall.ECONNRESET)),
+		"status 503":              &bench.AssertionError{Reason: bench.ReasonStatus, StatusCode: http.StatusServiceUnavailable},
+		bench.ErrorClassAssertion: &bench.AssertionError{Reason: bench.ReasonBody},
+		bench.ErrorClassOther:     errors.New("something else"),
+	}
+	for want, err := range tests {
+		got := bench.ClassifyError(err)
+		if want != got {
+			t.Errorf("%v: want class %q, got %q", err, want, got)
+		}
+	}
+}
+
+func runOneRequest(t *testing.T, URL string, client *http.Client) bench.Stats {
+	t.Helper()
+	tester, err := bench.NewTester(
+		bench.WithURL(URL),
+		bench.WithHTTPClient(client),
+		bench.WithStdout(io.Discard),
+		bench.WithStderr(io.Discard),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = tester.Run()
+	if err != nil && !errors.Is(err, bench.ErrTimeNotRecorded) {
+		t.Fatal(err)
+	}
+	return tester.Stats()
+}
+
+func TestRunClassifiesFailures(t *testing.T) {
+	t.Parallel()
+	unavailable := httptest.NewTLSServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		rw.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer unavailable.Close()
+	slow := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		time.Sleep(200 * time.Millisecond)
+	}))
+	defer slow.Close()
+	hangUp := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		conn, _, err := rw.(http.Hijacker).Hijack()
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		conn.Close()
+	}))
+	defer hangUp.Close()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	closedURL := "http://" + listener.Addr().String()
+	listener.Close()
+
+	tests := map[string]struct {
+		url    string
+		client *http.Client
+		want   map[string]int
+	}{
+		"status": {
+			url:    unavailable.URL,
+			client: unavailable.Client(),
+			want:   map[string]int{"status 503": 1},
+		},
+		"tls": {
+			url:    unavailable.URL,
+			client: &http.Client{},
+			want:   map[string]int{bench.ErrorClassTLS: 1},
+		},
+		"timeout": {
+			url:    slow.URL,
+			client: &http.Client{Timeout: 20 * time.Millisecond},
+			want:   map[string]int{bench.ErrorClassTimeout: 1},
+		},
+		"reset": {
+			url:    hangUp.URL,
+			client: &http.Client{},
+			want:   map[string]int{bench.ErrorClassReset: 1},
+		},
+		"connect": {
+			url:    closedURL,
+			client: &http.Client{},
+			want:   map[string]int{bench.ErrorClassConnect: 1},
+		},
+	}
+	for name, tc := range tests {
+		stats := runOneRequest(t, tc.url, tc.client)
+		if !cmp.Equal(tc.want, stats.Errors) {
+			t.Errorf("%s: %s", name, cmp.Diff(tc.want, stats.Errors))
+		}
+		if stats.Failures != 1 {
+			t.Errorf("%s: want 1 failure, got %d", name, stats.Failures)
+		}
+	}
+}
+
+func TestRunCountsStatusCodes(t *testing.T) {
+	t.Parallel()
+	server := httptest.NewTLSServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		rw.WriteHeader(http.StatusAccepted)
+	}))
+	tester, err := bench.NewTester(
+		bench.WithURL(server.URL),
+		bench.WithRequests(3),
+		bench.WithHTTPClient(server.Client()),
+		bench.WithStdout(io.Discard),
+		bench.WithStderr(io.Discard),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = tester.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[int]int{http.StatusAccepted: 3}
+	got := tester.Stats().StatusCodes
+	if !cmp.Equal(want, got) {
+		t.Error(cmp.Diff(want, got))
+	}
+}
+
+func TestRunPrintsErrorsAndStatusCodes(t *testing.T) {
+	t.Parallel()
+	server := httptest.NewTLSServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		rw.WriteHeader(http.StatusNotFound)
+	}))
+	stdout := &bytes.Buffer{}
+	tester, err := bench.NewTester(
+		bench.WithURL(server.URL),
+		bench.WithHTTPClient(server.Client()),
+		bench.WithExpectedInterval(time.Second),
+		bench.WithStdout(stdout),
+		bench.WithStderr(io.Discard),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = tester.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"Status codes: 404: 1\n", "Errors: status 404: 1\n", "Assertion failures: status: 1\n"} {
+		if !bytes.Contains(stdout.Bytes(), []byte(want)) {
+			t.Errorf("want output to contain %q, got %q", want, stdout)
+		}
+	}
+}
+
+func TestWriteStatsFileAndReadStatsFileRoundTripErrors(t *testing.T) {
+	t.Parallel()
+	want := bench.Stats{
+		URL:       "http://fake.url",
+		Requests:  20,
+		Successes: 15,
+		Failures:  5,
+		Errors: map[string]int{
+			bench.ErrorClassTimeout: 2,
+			"status 503":            3,
+		},
+		StatusCodes: map[int]int{
+			200: 15,
+			503: 3,
+		},
+	}
+	output := &bytes.Buffer{}
+	err := bench.WriteStatsFile(output, want)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := bench.ReadStatsFile(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !cmp.Equal([]bench.Stats{want}, got) {
+		t.Error(cmp.Diff([]bench.Stats{want}, got))
+	}
+}
+
+func TestCompareStatsComparesErrorsAndStatusCodes(t *testing.T) {
+	t.Parallel()
+	stats1 := bench.Stats{
+		Errors:      map[string]int{bench.ErrorClassTimeout: 2, bench.ErrorClassReset: 1},
+		StatusCodes: map[int]int{200: 10},
+	}
+	stats2 := bench.Stats{
+		Errors:      map[string]int{bench.ErrorClassTimeout: 5},
+		StatusCodes: map[int]int{200: 8, 503: 2},
+	}
+	want := bench.StatsDelta{
+		Errors:      map[string]int{bench.ErrorClassTimeout: 3, bench.ErrorClassReset: -1},
+		StatusCodes: map[int]int{200: -2, 503: 2},
+	}
+	got := bench.CompareStats(stats1, stats2)
+	if !cmp.Equal(want, got) {
+		t.Error(cmp.Diff(want, got))
+	}
+}
@@ -0,0 +1,107 @@
+package bench
+
+import (
+	"errors"
+	"fmt"
+	"io"
+)
+
+var ErrBudgetExceeded = errors.New("regression budget exceeded")
+
+// Budget is how far a run may regress from a previous one. Increases and
+// decreases are fractions of the old value, so 0.1 allows 10% more; the
+// error rate is the fraction of the new run's requests that failed. Zero
+// fields are not checked.
+type Budget struct {
+	MaxP50Increase  float64
+	MaxP90Increase  float64
+	MaxP99Increase  float64
+	MaxMeanIncrease float64
+	MaxErrorRate    float64
+	MaxRPSDecrease  float64
+}
+
+// Violation is a metric that went over its budget. Value is the relative
+// change of the metric, or the error rate, and Limit its budget.
+type Violation struct {
+	URL      string
+	Endpoint string
+	Metric   string
+	Value    float64
+	Limit    float64
+}
+
+func (v Violation) String() string {
+	change := "increased"
+	switch v.Metric {
+	case "error rate":
+		return fmt.Sprintf("%s: error rate %.2f%%, over the limit of %.2f%%", entryTitle(v.URL, v.Endpoint), v.Value*100, v.Limit*100)
+	case "req/s":
+		change = "decreased"
+	}
+	return fmt.Sprintf("%s: %s %s %.2f%%, over the limit of %.2f%%", entryTitle(v.URL, v.Endpoint), v.Metric, change, v.Value*100, v.Limit*100)
+}
+
+// CheckBudget returns the metrics of the run that changed from base by delta
+// beyond the budget. Relative changes are not checked for metrics that were
+// zero in base.
+func CheckBudget(base Stats, delta StatsDelta, b Budget) []Violation {
+	var violations []Violation
+	check := func(metric string, value, limit float64) {
+		if limit > 0 && value > limit {
+			violations = append(violations, Violation{
+				URL:      base.URL,
+				Endpoint: base.Endpoint,
+				Metric:   metric,
+				Value:    value,
+				Limit:    limit,
+			})
+		}
+	}
+	relative := func(delta, old float64) float64 {
+		if old == 0 {
+			return 0
+		}
+		return delta / old
+	}
+	check("P50", relative(delta.P50, base.P50), b.MaxP50Increase)
+	check("P90", relative(delta.P90, base.P90), b.MaxP90Increase)
+	check("P99", relative(delta.P99, base.P99), b.MaxP99Increase)
+	check("mean", relative(delta.Mean, base.Mean), b.MaxMeanIncrease)
+	if requests := base.Requests + delta.Requests; requests > 0 {
+		check("error rate", float64(base.Failures+delta.Failures)/float64(requests), b.MaxErrorRate)
+	}
+	check("req/s", relative(-delta.RequestsPerSecond, base.RequestsPerSecond()), b.MaxRPSDecrease)
+	return violations
+}
+
+// CheckComparisons checks every comparison against the budget, leaving out
+// latency increases that are not significant at alpha.
+func CheckComparisons(comparisons []Comparison, b Budget, alpha float64) []Violation {
+	var violations []Violation
+	for _, c := range comparisons {
+		for _, v := range CheckBudget(c.Old, c.Delta, b) {
+			if v.Metric != "error rate" && v.Metric != "req/s" && !c.Significant(alpha) {
+				continue
+			}
+			violations = append(violations, v)
+		}
+	}
+	return violations
+}
+
+// WriteViolations reports each violation on a line, or that every metric is
+// within budget, and returns ErrBudgetExceeded if there are any.
+func WriteViolations(w io.Writer, violations []Violation) error {
+	if len(violations) == 0 {
+		_, err := fmt.Fprintln(w, "PASS: every metric is within budget")
+		return err
+	}
+	for _, v := range violations {
+		_, err := fmt.Fprintf(w, "FAIL: %s\n", v)
+		if err != nil {
+			return err
+		}
+	}
+	return ErrBudgetExceeded
+}
@@ -0,0 +1,102 @@
+package bench_test
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/thiagonache/bench"
+)
+
+func TestCheckBudget_ReportsMetricsOverBudget(t *testing.T) {
+	t.Parallel()
+	base := bench.Stats{
+		URL:      "http://fake.url",
+		P50:      10,
+		P90:      20,
+		P99:      100,
+		Mean:     12,
+		Requests: 100,
+		Duration: 10 * time.Second,
+	}
+	current := bench.Stats{
+		URL:      "http://fake.url",
+		P50:      10.5,
+		P90:      20,
+		P99:      115,
+		Mean:     12,
+		Requests: 100,
+		Failures: 2,
+		Duration: 20 * time.Second,
+	}
+	budget := bench.Budget{
+		MaxP50Increase: 0.1,
+		MaxP99Increase: 0.1,
+		MaxErrorRate:   0.01,
+		MaxRPSDecrease: 0.2,
+	}
+	want := []bench.Violation{
+		{URL: "http://fake.url", Metric: "P99", Value: 0.15, Limit: 0.1},
+		{URL: "http://fake.url", Metric: "error rate", Value: 0.02, Limit: 0.01},
+		{URL: "http://fake.url", Metric: "req/s", Value: 0.5, Limit: 0.2},
+	}
+	got := bench.CheckBudget(base, bench.CompareStats(base, current), budget)
+	if !cmp.Equal(want, got) {
+		t.Error(cmp.Diff(want, got))
+	}
+}
+
+func TestCheckBudget_ReportsNothingWithinBudget(t *testing.T) {
+	t.Parallel()
+	base := bench.Stats{P99: 100, Requests: 100}
+	current := bench.Stats{P99: 105, Requests: 100}
+	got := bench.CheckBudget(base, bench.CompareStats(base, current), bench.Budget{MaxP99Increase: 0.1, MaxErrorRate: 0.01})
+	if len(got) != 0 {
+		t.Errorf("want no violations, got %v", got)
+	}
+}
+
+func TestCheckComparisons_IgnoresLatencyIncreasesThatAreNotSignificant(t *testing.T) {
+	t.Parallel()
+	comparisons, err := bench.MatchStats(
+		[]bench.Stats{{P99: 5, Requests: 3, Samples: []float64{1, 3, 5}}},
+		[]bench.Stats{{P99: 6, Requests: 3, Failures: 1, Samples: []float64{1, 2, 6}}},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := bench.CheckComparisons(comparisons, bench.Budget{MaxP99Increase: 0.1, MaxErrorRate: 0.1}, bench.DefaultAlpha)
+	if len(got) != 1 || got[0].Metric != "error rate" {
+		t.Errorf("want only the error rate over budget, got %v", got)
+	}
+}
+
+func TestWriteViolations_ReportsEachViolationAndErrors(t *testing.T) {
+	t.Parallel()
+	buf := &bytes.Buffer{}
+	err := bench.WriteViolations(buf, []bench.Violation{
+		{URL: "http://fake.url", Metric: "P99", Value: 0.15, Limit: 0.1},
+		{URL: "http://fake.url/a", Endpoint: "GET /a", Metric: "error rate", Value: 0.02, Limit: 0.01},
+		{URL: "http://fake.url", Metric: "req/s", Value: 0.5, Limit: 0.2},
+	})
+	if !errors.Is(err, bench.ErrBudgetExceeded) {
+		t.Errorf("want ErrBudgetExceeded, got %v", err)
+	}
+	want := "FAIL: http://fake.url: P99 increased 15.00%, over the limit of 10.00%\n" +
+		"FAIL: GET /a (http://fake.url/a): error rate 2.00%, over the limit of 1.00%\n" +
+		"FAIL: http://fake.url: req/s decreased 50.00%, over the limit of 20.00%\n"
+	got := buf.String()
+	if want != got {
+		t.Error(cmp.Diff(want, got))
+	}
+	buf.Reset()
+	err = bench.WriteViolations(buf, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if buf.String() != "PASS: every metric is within budget\n" {
+		t.Errorf("want pass line, got %q", buf)
+	}
+}
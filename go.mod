@@ -5,6 +5,7 @@ go 1.17
 require (
 	github.com/google/go-cmp v0.5.6
 	gonum.org/v1/plot v0.10.0
+	gopkg.in/yaml.v3 v3.0.1
 )
 
 require (
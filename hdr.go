@@ -0,0 +1,207 @@
+package bench
+
+import (
+	"fmt"
+	"math"
+	"math/bits"
+	"sync/atomic"
+)
+
+const (
+	// HDRHighestTrackableValue is one hour in microseconds; slower samples
+	// are clamped to it.
+	HDRHighestTrackableValue = int64(3600 * 1000 * 1000)
+	maxPlotValues            = 10000
+)
+
+// HDRRecorder is a high dynamic range histogram of latencies in microsecond
+// units. Recording is lock-free, so every worker can share one instance, and
+// memory is fixed by the precision rather than by the number of samples.
+type HDRRecorder struct {
+	subBucketHalfCountMagnitude int
+	subBucketHalfCount          int
+	subBucketMask               int64
+	counts                      []int64
+	totalCount                  int64
+	sum                         int64
+	min                         int64
+	max                         int64
+}
+
+func NewHDRRecorder(significantFigures int) (*HDRRecorder, error) {
+	if significantFigures < 1 || significantFigures > 5 {
+		return nil, fmt.Errorf("%d is invalid number of significant figures", significantFigures)
+	}
+	return newHDRRecorder(significantFigures), nil
+}
+
+// newHDRRecorder is NewHDRRecorder for a number of significant figures known
+// to be valid.
+func newHDRRecorder(significantFigures int) *HDRRecorder {
+	largestValueWithSingleUnitResolution := 2 * math.Pow10(significantFigures)
+	subBucketCountMagnitude := int(math.Ceil(math.Log2(largestValueWithSingleUnitResolution)))
+	subBucketCount := int64(1) << subBucketCountMagnitude
+	bucketCount := 1
+	for smallestUntrackable := subBucketCount; smallestUntrackable <= HDRHighestTrackableValue; smallestUntrackable <<= 1 {
+		bucketCount++
+	}
+	h := &HDRRecorder{
+		subBucketHalfCountMagnitude: subBucketCountMagnitude - 1,
+		subBucketHalfCount:          int(subBucketCount / 2),
+		subBucketMask:               subBucketCount - 1,
+		min:                         math.MaxInt64,
+	}
+	h.counts = make([]int64, (bucketCount+1)*h.subBucketHalfCount)
+	return h
+}
+
+func (h *HDRRecorder) RecordTime(executionTime float64) {
+	v := int64(math.Round(executionTime * 1000))
+	if v < 0 {
+		v = 0
+	}
+	if v > HDRHighestTrackableValue {
+		v = HDRHighestTrackableValue
+	}
+	atomic.AddInt64(&h.counts[h.countsIndex(v)], 1)
+	atomic.AddInt64(&h.totalCount, 1)
+	atomic.AddInt64(&h.sum, v)
+	for {
+		min := atomic.LoadInt64(&h.min)
+		if v >= min || atomic.CompareAndSwapInt64(&h.min, min, v) {
+			break
+		}
+	}
+	for {
+		max := atomic.LoadInt64(&h.max)
+		if v <= max || atomic.CompareAndSwapInt64(&h.max, max, v) {
+			break
+		}
+	}
+}
+
+func (h *HDRRecorder) Count() int {
+	return int(atomic.LoadInt64(&h.totalCount))
+}
+
+func (h *HDRRecorder) Min() float64 {
+	if atomic.LoadInt64(&h.totalCount) == 0 {
+		return 0
+	}
+	return float64(atomic.LoadInt64(&h.min)) / 1000
+}
+
+func (h *HDRRecorder) Max() float64 {
+	return float64(atomic.LoadInt64(&h.max)) / 1000
+}
+
+func (h *HDRRecorder) Mean() float64 {
+	count := atomic.LoadInt64(&h.totalCount)
+	if count == 0 {
+		return 0
+	}
+	return float64(atomic.LoadInt64(&h.sum)) / float64(count) / 1000
+}
+
+// StdDev is computed from the bucket values, so it carries the same
+// precision as the histogram.
+func (h *HDRRecorder) StdDev() float64 {
+	count := atomic.LoadInt64(&h.totalCount)
+	if count == 0 {
+		return 0
+	}
+	mean := h.Mean() * 1000
+	variance := 0.0
+	for i := range h.counts {
+		n := atomic.LoadInt64(&h.counts[i])
+		if n == 0 {
+			continue
+		}
+		deviation := float64(h.medianEquivalentValue(h.valueFromIndex(i))) - mean
+		variance += deviation * deviation * float64(n)
+	}
+	return math.Sqrt(variance/float64(count)) / 1000
+}
+
+// Percentile returns the highest value equivalent, within the configured
+// precision, to the sample at the p (0 to 1) quantile.
+func (h *HDRRecorder) Percentile(p float64) float64 {
+	count := atomic.LoadInt64(&h.totalCount)
+	if count == 0 {
+		return 0
+	}
+	countAtPercentile := int64(p*float64(count) + 0.5)
+	if countAtPercentile < 1 {
+		countAtPercentile = 1
+	}
+	var total int64
+	for i := range h.counts {
+		total += atomic.LoadInt64(&h.counts[i])
+		if total >= countAtPercentile {
+			v := h.highestEquivalentValue(h.valueFromIndex(i))
+			if max := atomic.LoadInt64(&h.max); v > max {
+				v = max
+			}
+			return float64(v) / 1000
+		}
+	}
+	return float64(atomic.LoadInt64(&h.max)) / 1000
+}
+
+// Values reconstructs samples for plotting. Small histograms are expanded in
+// full; larger ones are summarised by evenly spaced quantiles, which keeps the
+// shape of the distribution without allocating one value per request.
+func (h *HDRRecorder) Values() []float64 {
+	count := h.Count()
+	if count > maxPlotValues {
+		values := make([]float64, maxPlotValues)
+		for i := range values {
+			values[i] = h.Percentile((float64(i) + 0.5) / maxPlotValues)
+		}
+		return values
+	}
+	values := make([]float64, 0, count)
+	for i := range h.counts {
+		n := atomic.LoadInt64(&h.counts[i])
+		if n == 0 {
+			continue
+		}
+		v := float64(h.medianEquivalentValue(h.valueFromIndex(i))) / 1000
+		for j := int64(0); j < n; j++ {
+			values = append(values, v)
+		}
+	}
+	return values
+}
+
+func (h *HDRRecorder) bucketIndex(v int64) int {
+	return bits.Len64(uint64(v|h.subBucketMask)) - (h.subBucketHalfCountMagnitude + 1)
+}
+
+func (h *HDRRecorder) countsIndex(v int64) int {
+	bucketIdx := h.bucketIndex(v)
+	subBucketIdx := int(v >> bucketIdx)
+	return (bucketIdx+1)<<h.subBucketHalfCountMagnitude + subBucketIdx - h.subBucketHalfCount
+}
+
+func (h *HDRRecorder) valueFromIndex(i int) int64 {
+	bucketIdx := (i >> h.subBucketHalfCountMagnitude) - 1
+	subBucketIdx := (i & (h.subBucketHalfCount - 1)) + h.subBucketHalfCount
+	if bucketIdx < 0 {
+		subBucketIdx -= h.subBucketHalfCount
+		bucketIdx = 0
+	}
+	return int64(subBucketIdx) << bucketIdx
+}
+
+func (h *HDRRecorder) equivalentRange(v int64) int64 {
+	return int64(1) << h.bucketIndex(v)
+}
+
+func (h *HDRRecorder) highestEquivalentValue(v int64) int64 {
+	return v + h.equivalentRange(v) - 1
+}
+
+func (h *HDRRecorder) medianEquivalentValue(v int64) int64 {
+	return v + h.equivalentRange(v)>>1
+}
@@ -0,0 +1,168 @@
+package bench_test
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/thiagonache/bench"
+)
+
+func TestNewHDRRecorderWithInvalidSignificantFiguresReturnsError(t *testing.T) {
+	t.Parallel()
+	for _, sf := range []int{0, 6, -1} {
+		_, err := bench.NewHDRRecorder(sf)
+		if err == nil {
+			t.Errorf("want error for %d significant figures", sf)
+		}
+	}
+}
+
+func TestHDRRecorderPercentilesAreWithinConfiguredPrecision(t *testing.T) {
+	t.Parallel()
+	h, err := bench.NewHDRRecorder(3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 1; i <= 10000; i++ {
+		h.RecordTime(float64(i) / 10)
+	}
+	if h.Count() != 10000 {
+		t.Fatalf("want 10000 samples recorded, got %d", h.Count())
+	}
+	cases := map[float64]float64{
+		0.5:  500,
+		0.9:  900,
+		0.99: 990,
+		1:    1000,
+	}
+	for p, want := range cases {
+		got := h.Percentile(p)
+		if math.Abs(got-want)/want > 0.001 {
+			t.Errorf("percentile %v: want %v within 0.1%%, got %v", p, want, got)
+		}
+	}
+	wantMean := 500.05
+	if math.Abs(h.Mean()-wantMean) > 0.001 {
+		t.Errorf("want mean %v, got %v", wantMean, h.Mean())
+	}
+}
+
+func TestHDRRecorderRecordsConcurrentlyWithoutLosingSamples(t *testing.T) {
+	t.Parallel()
+	h, err := bench.NewHDRRecorder(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wg := sync.WaitGroup{}
+	for w := 0; w < 8; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 1000; i++ {
+				h.RecordTime(12.5)
+			}
+		}()
+	}
+	wg.Wait()
+	if h.Count() != 8000 {
+		t.Errorf("want 8000 samples, got %d", h.Count())
+	}
+}
+
+func TestHDRRecorderValuesExpandSmallHistogramsAndSampleLargeOnes(t *testing.T) {
+	t.Parallel()
+	h, err := bench.NewHDRRecorder(3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h.RecordTime(5)
+	h.RecordTime(5)
+	h.RecordTime(10)
+	got := h.Values()
+	if len(got) != 3 {
+		t.Fatalf("want 3 values, got %v", got)
+	}
+	for i := 0; i < 100000; i++ {
+		h.RecordTime(float64(i % 1000))
+	}
+	if len(h.Values()) >= h.Count() {
+		t.Errorf("want values sampled for %d samples, got %d", h.Count(), len(h.Values()))
+	}
+}
+
+func TestNewTesterWithHDRRecorderUsesHDRRecorder(t *testing.T) {
+	t.Parallel()
+	tester, err := bench.NewTester(
+		bench.WithURL("http://fake.url"),
+		bench.WithHDRRecorder(3),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := tester.TimeRecorder.(*bench.HDRRecorder); !ok {
+		t.Errorf("want *bench.HDRRecorder, got %T", tester.TimeRecorder)
+	}
+}
+
+func TestFromArgsHDRFlagConfiguresHDRRecorder(t *testing.T) {
+	t.Parallel()
+	tester, err := bench.NewTester(
+		bench.WithStderr(io.Discard),
+		bench.FromArgs([]string{"run", "-hdr", "2", "-u", "http://fake.url"}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := tester.TimeRecorder.(*bench.HDRRecorder); !ok {
+		t.Errorf("want *bench.HDRRecorder, got %T", tester.TimeRecorder)
+	}
+}
+
+func TestNewTesterByDefaultUsesTimeRecorder(t *testing.T) {
+	t.Parallel()
+	tester, err := bench.NewTester(
+		bench.WithURL("http://fake.url"),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := tester.TimeRecorder.(*bench.TimeRecorder); !ok {
+		t.Errorf("want *bench.TimeRecorder, got %T", tester.TimeRecorder)
+	}
+}
+
+func TestRunWithHDRRecorderReturnsValidStats(t *testing.T) {
+	t.Parallel()
+	server := httptest.NewTLSServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(rw, "HelloWorld")
+	}))
+	tester, err := bench.NewTester(
+		bench.WithURL(server.URL),
+		bench.WithRequests(50),
+		bench.WithHDRRecorder(3),
+		bench.WithHTTPClient(server.Client()),
+		bench.WithOutputPath(t.TempDir()),
+		bench.WithGraphs(true),
+		bench.WithStdout(io.Discard),
+		bench.WithStderr(io.Discard),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = tester.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stats := tester.Stats()
+	if stats.Successes != 50 {
+		t.Errorf("want 50 successes, got %d", stats.Successes)
+	}
+	if stats.P50 <= 0 || stats.P50 > stats.P99 {
+		t.Errorf("want 0 < P50 <= P99, got P50 %v and P99 %v", stats.P50, stats.P99)
+	}
+}
@@ -0,0 +1,170 @@
+package bench
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net/http/httptrace"
+	"sync"
+	"time"
+
+	"gonum.org/v1/plot"
+	"gonum.org/v1/plot/plotter"
+	"gonum.org/v1/plot/plotutil"
+	"gonum.org/v1/plot/vg"
+)
+
+const (
+	PhaseDNS     = "dns"
+	PhaseConnect = "connect"
+	PhaseTLS     = "tls"
+	PhaseTTFB    = "ttfb"
+	PhaseBody    = "body"
+)
+
+// Phases are the parts of a request timed separately, in the order they
+// happen. DNS, connect and TLS are only timed when a new connection is
+// opened; TTFB runs from getting a connection to the first response byte.
+var Phases = []string{PhaseDNS, PhaseConnect, PhaseTLS, PhaseTTFB, PhaseBody}
+
+// PhaseStats are the latency statistics, in milliseconds, of one phase.
+type PhaseStats struct {
+	Phase       string
+	Count       int
+	Mean        float64
+	Percentiles []Percentile
+}
+
+// phaseTimer collects the httptrace events of one request. Dialing may try
+// several addresses at once, so events are guarded by a mutex.
+type phaseTimer struct {
+	mu           sync.Mutex
+	dnsStart     time.Time
+	dnsDone      time.Time
+	connectStart time.Time
+	connectDone  time.Time
+	tlsStart     time.Time
+	tlsDone      time.Time
+	gotConn      time.Time
+	firstByte    time.Time
+}
+
+func (p *phaseTimer) trace() *httptrace.ClientTrace {
+	set := func(t *time.Time) {
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		*t = time.Now()
+	}
+	return &httptrace.ClientTrace{
+		DNSStart: func(httptrace.DNSStartInfo) { set(&p.dnsStart) },
+		DNSDone:  func(httptrace.DNSDoneInfo) { set(&p.dnsDone) },
+		ConnectStart: func(network, addr string) {
+			p.mu.Lock()
+			defer p.mu.Unlock()
+			if p.connectStart.IsZero() {
+				p.connectStart = time.Now()
+			}
+		},
+		ConnectDone: func(network, addr string, err error) {
+			if err == nil {
+				set(&p.connectDone)
+			}
+		},
+		TLSHandshakeStart: func() { set(&p.tlsStart) },
+		TLSHandshakeDone: func(_ tls.ConnectionState, err error) {
+			if err == nil {
+				set(&p.tlsDone)
+			}
+		},
+		GotConn:              func(httptrace.GotConnInfo) { set(&p.gotConn) },
+		GotFirstResponseByte: func() { set(&p.firstByte) },
+	}
+}
+
+// durations returns how long each phase that happened took, given when the
+// body was fully read.
+func (p *phaseTimer) durations(bodyDone time.Time) map[string]time.Duration {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	d := map[string]time.Duration{}
+	between := func(phase string, start, end time.Time) {
+		if !start.IsZero() && !end.IsZero() {
+			d[phase] = end.Sub(start)
+		}
+	}
+	between(PhaseDNS, p.dnsStart, p.dnsDone)
+	between(PhaseConnect, p.connectStart, p.connectDone)
+	between(PhaseTLS, p.tlsStart, p.tlsDone)
+	between(PhaseTTFB, p.gotConn, p.firstByte)
+	between(PhaseBody, p.firstByte, bodyDone)
+	return d
+}
+
+func (t *Tester) recordPhases(p *phaseTimer, bodyDone time.Time) {
+	for phase, d := range p.durations(bodyDone) {
+		t.phaseRecorders[phase].RecordTime(milliseconds(d))
+	}
+}
+
+func (t *Tester) phaseMetrics() []PhaseStats {
+	stats := []PhaseStats{}
+	for _, phase := range Phases {
+		r := t.phaseRecorders[phase]
+		if r.Count() == 0 {
+			continue
+		}
+		stats = append(stats, PhaseStats{
+			Phase:       phase,
+			Count:       r.Count(),
+			Mean:        r.Mean(),
+			Percentiles: percentiles(r, t.percentiles),
+		})
+	}
+	if len(stats) == 0 {
+		return nil
+	}
+	return stats
+}
+
+// Phase returns the statistics of the named phase, if it was timed.
+func (s Stats) Phase(phase string) (PhaseStats, bool) {
+	for _, p := range s.Phases {
+		if p.Phase == phase {
+			return p, true
+		}
+	}
+	return PhaseStats{}, false
+}
+
+// PhasesGraph draws one bar per statistic, the mean and each percentile,
+// stacking the time spent in every phase.
+func (t Tester) PhasesGraph() error {
+	p := plot.New()
+	p.Title.Text = "Latency by phase"
+	p.Y.Label.Text = "latency (ms)"
+	labels := []string{"Mean"}
+	for _, pc := range t.stats.Percentiles {
+		labels = append(labels, pc.Label())
+	}
+	w := vg.Points(20)
+	var below *plotter.BarChart
+	for i, phase := range t.stats.Phases {
+		values := plotter.Values{phase.Mean}
+		for _, pc := range phase.Percentiles {
+			values = append(values, pc.Value)
+		}
+		bars, err := plotter.NewBarChart(values, w)
+		if err != nil {
+			return err
+		}
+		bars.Color = plotutil.Color(i)
+		if below != nil {
+			bars.StackOn(below)
+		}
+		p.Add(bars)
+		p.Legend.Add(phase.Phase, bars)
+		below = bars
+	}
+	p.Legend.Top = true
+	p.NominalX(labels...)
+	return p.Save(600, 400, fmt.Sprintf("%s/%s", t.OutputPath, "phases.png"))
+}
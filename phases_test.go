@@ -0,0 +1,153 @@
+package bench_test
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/thiagonache/bench"
+)
+
+func TestRunRecordsRequestPhases(t *testing.T) {
+	t.Parallel()
+	server := httptest.NewTLSServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		time.Sleep(20 * time.Millisecond)
+		rw.Write(bytes.Repeat([]byte("x"), 1024))
+	}))
+	tester, err := bench.NewTester(
+		bench.WithURL(server.URL),
+		bench.WithRequests(5),
+		bench.WithHTTPClient(server.Client()),
+		bench.WithStdout(io.Discard),
+		bench.WithStderr(io.Discard),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = tester.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stats := tester.Stats()
+	want := map[string]int{
+		bench.PhaseConnect: 1,
+		bench.PhaseTLS:     1,
+		bench.PhaseTTFB:    5,
+		bench.PhaseBody:    5,
+	}
+	got := map[string]int{}
+	for _, p := range stats.Phases {
+		got[p.Phase] = p.Count
+	}
+	if !cmp.Equal(want, got) {
+		t.Error(cmp.Diff(want, got))
+	}
+	ttfb, ok := stats.Phase(bench.PhaseTTFB)
+	if !ok {
+		t.Fatal("want TTFB phase")
+	}
+	if ttfb.Mean < 20 {
+		t.Errorf("want TTFB to include the 20ms server time, got %.3fms", ttfb.Mean)
+	}
+	if len(ttfb.Percentiles) != len(bench.DefaultPercentiles) {
+		t.Errorf("want TTFB percentiles %v, got %v", bench.DefaultPercentiles, ttfb.Percentiles)
+	}
+}
+
+func TestRunRecordsDNSPhaseForHostNames(t *testing.T) {
+	t.Parallel()
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+	stdout := &bytes.Buffer{}
+	tester, err := bench.NewTester(
+		bench.WithURL(strings.Replace(server.URL, "127.0.0.1", "localhost", 1)),
+		bench.WithHTTPClient(&http.Client{}),
+		bench.WithStdout(stdout),
+		bench.WithStderr(io.Discard),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = tester.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, ok := tester.Stats().Phase(bench.PhaseDNS)
+	if !ok {
+		t.Error("want DNS phase recorded")
+	}
+	if !strings.Contains(stdout.String(), "Phase dns: Mean: ") {
+		t.Errorf("want DNS phase in summary, got %q", stdout)
+	}
+}
+
+func TestRunWithGraphsWritesPhasesGraph(t *testing.T) {
+	t.Parallel()
+	server := httptest.NewTLSServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {}))
+	tempDir := t.TempDir()
+	tester, err := bench.NewTester(
+		bench.WithURL(server.URL),
+		bench.WithGraphs(true),
+		bench.WithOutputPath(tempDir),
+		bench.WithHTTPClient(server.Client()),
+		bench.WithStdout(io.Discard),
+		bench.WithStderr(io.Discard),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = tester.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = os.Stat(tempDir + "/phases.png")
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestWriteStatsFileAndReadStatsFileRoundTripPhases(t *testing.T) {
+	t.Parallel()
+	want := bench.Stats{
+		URL:      "http://fake.url",
+		Requests: 10,
+		Phases: []bench.PhaseStats{
+			{
+				Phase: bench.PhaseConnect,
+				Count: 1,
+				Mean:  0.5,
+				Percentiles: []bench.Percentile{
+					{P: 50, Value: 0.5},
+					{P: 99, Value: 0.5},
+				},
+			},
+			{
+				Phase: bench.PhaseTTFB,
+				Count: 10,
+				Mean:  20.25,
+				Percentiles: []bench.Percentile{
+					{P: 50, Value: 20},
+					{P: 99, Value: 25.125},
+				},
+			},
+		},
+	}
+	output := &bytes.Buffer{}
+	err := bench.WriteStatsFile(output, want)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := bench.ReadStatsFile(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !cmp.Equal([]bench.Stats{want}, got) {
+		t.Error(cmp.Diff([]bench.Stats{want}, got))
+	}
+}
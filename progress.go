@@ -0,0 +1,135 @@
+package bench
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// WithProgress reports progress on stderr every interval while the benchmark
+// runs: requests completed, current throughput, P50 and P99 over the last
+// interval, errors and the estimated time left. A terminal gets a single line
+// updated in place; anything else, such as a CI log, gets a line per
+// interval.
+func WithProgress(interval time.Duration) Option {
+	return func(t *Tester) error {
+		if interval < 0 {
+			return fmt.Errorf("%v is invalid progress interval", interval)
+		}
+		t.progressInterval = interval
+		return nil
+	}
+}
+
+func (t Tester) ProgressInterval() time.Duration {
+	return t.progressInterval
+}
+
+// progress keeps the latencies recorded since the last report.
+type progress struct {
+	mu        *sync.Mutex
+	latencies []float64
+}
+
+func (p *progress) add(ms float64) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.latencies = append(p.latencies, ms)
+}
+
+func (p *progress) take() []float64 {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	latencies := p.latencies
+	p.latencies = nil
+	return latencies
+}
+
+// startProgress reports progress until the returned function is called.
+func (t *Tester) startProgress() func() {
+	if t.progressInterval == 0 {
+		return func() {}
+	}
+	t.progress = &progress{mu: &sync.Mutex{}}
+	tty := isTerminal(t.stderr)
+	done := make(chan struct{})
+	finished := make(chan struct{})
+	go func() {
+		defer close(finished)
+		ticker := time.NewTicker(t.progressInterval)
+		defer ticker.Stop()
+		last, lastRequests := t.startAt, 0
+		for {
+			select {
+			case now := <-ticker.C:
+				var line string
+				line, lastRequests = t.progressLine(now, now.Sub(last), lastRequests)
+				last = now
+				if tty {
+					t.LogFStdErr("\rprogress: %s\x1b[K", line)
+					continue
+				}
+				t.LogFStdErr("progress: %s\n", line)
+			case <-done:
+				if tty && last != t.startAt {
+					t.LogStdErr("\n")
+				}
+				return
+			}
+		}
+	}()
+	return func() {
+		close(done)
+		<-finished
+	}
+}
+
+// progressLine describes the run so far, given how long ago the last report
+// was and how many requests had completed by then. It returns how many have
+// completed now.
+func (t *Tester) progressLine(now time.Time, interval time.Duration, lastRequests int) (string, int) {
+	t.mu.Lock()
+	completed, failures := t.stats.Requests, t.stats.Failures
+	t.mu.Unlock()
+	window := NewTimeRecorder()
+	for _, ms := range t.progress.take() {
+		window.RecordTime(ms)
+	}
+	rps := float64(completed-lastRequests) / interval.Seconds()
+	line := fmt.Sprintf("%d requests", completed)
+	if t.duration == 0 {
+		line = fmt.Sprintf("%d/%d requests", completed, t.requests)
+	}
+	line += fmt.Sprintf(", %.2f req/s", rps)
+	if window.Count() > 0 {
+		line += fmt.Sprintf(", P50 %.3fms, P99 %.3fms", window.Percentile(0.5), window.Percentile(0.99))
+	}
+	line += fmt.Sprintf(", %d errors", failures)
+	elapsed := now.Sub(t.startAt)
+	switch {
+	case t.duration > 0:
+		eta := t.duration - elapsed
+		if eta < 0 {
+			eta = 0
+		}
+		line += fmt.Sprintf(", ETA %v", eta.Round(time.Second))
+	case completed > 0:
+		perRequest := elapsed / time.Duration(completed)
+		line += fmt.Sprintf(", ETA %v", (perRequest * time.Duration(t.requests-completed)).Round(time.Second))
+	}
+	return line, completed
+}
+
+func isTerminal(w io.Writer) bool {
+	f, ok := w.(*os.File)
+	if !ok {
+		return false
+	}
+	info, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	return info.Mode()&os.ModeCharDevice != 0
+}
@@ -0,0 +1,114 @@
+package bench_test
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/thiagonache/bench"
+)
+
+func TestRun_ReportsProgressLinePerInterval(t *testing.T) {
+	t.Parallel()
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		time.Sleep(5 * time.Millisecond)
+	}))
+	defer server.Close()
+	stderr := &bytes.Buffer{}
+	tester, err := bench.NewTester(
+		bench.WithURL(server.URL),
+		bench.WithRequests(30),
+		bench.WithProgress(20*time.Millisecond),
+		bench.WithStdout(io.Discard),
+		bench.WithStderr(stderr),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = tester.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	line := regexp.MustCompile(`^progress: \d+/30 requests, [\d.]+ req/s(, P50 [\d.]+ms, P99 [\d.]+ms)?, 0 errors, ETA \d+s$`)
+	lines := strings.Split(strings.TrimSuffix(stderr.String(), "\n"), "\n")
+	if len(lines) < 2 {
+		t.Fatalf("want a progress line per interval, got %q", stderr.String())
+	}
+	for _, l := range lines {
+		if !line.MatchString(l) {
+			t.Errorf("unexpected progress line %q", l)
+		}
+	}
+	if strings.Contains(stderr.String(), "\r") {
+		t.Errorf("want no carriage returns outside a terminal, got %q", stderr.String())
+	}
+}
+
+func TestRun_ReportsTimeLeftInDurationMode(t *testing.T) {
+	t.Parallel()
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		time.Sleep(time.Millisecond)
+	}))
+	defer server.Close()
+	stderr := &bytes.Buffer{}
+	tester, err := bench.NewTester(
+		bench.WithURL(server.URL),
+		bench.WithDuration(100*time.Millisecond),
+		bench.WithProgress(30*time.Millisecond),
+		bench.WithStdout(io.Discard),
+		bench.WithStderr(stderr),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = tester.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !regexp.MustCompile(`(?m)^progress: \d+ requests, .*, ETA 0s$`).MatchString(stderr.String()) {
+		t.Errorf("want progress lines with requests and time left, got %q", stderr.String())
+	}
+}
+
+func TestRun_ReportsNoProgressByDefault(t *testing.T) {
+	t.Parallel()
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+	stderr := &bytes.Buffer{}
+	tester, err := bench.NewTester(
+		bench.WithURL(server.URL),
+		bench.WithRequests(5),
+		bench.WithStdout(io.Discard),
+		bench.WithStderr(stderr),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = tester.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stderr.Len() != 0 {
+		t.Errorf("want no progress, got %q", stderr.String())
+	}
+}
+
+func TestFromArgs_SetsProgressInterval(t *testing.T) {
+	t.Parallel()
+	tester, err := bench.NewTester(
+		bench.FromArgs([]string{"run", "-u", "http://fake.url", "-progress", "5s"}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := 5 * time.Second
+	got := tester.ProgressInterval()
+	if want != got {
+		t.Errorf("want progress interval %v, got %v", want, got)
+	}
+}
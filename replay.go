@@ -0,0 +1,306 @@
+package bench
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const accessLogTimeLayout = "02/Jan/2006:15:04:05 -0700"
+
+var (
+	ErrNoReplayRequests = errors.New("no requests to replay")
+
+	accessLogLine = regexp.MustCompile(`^\S+ \S+ \S+ \[([^\]]+)\] "([^"]*)"`)
+	// harSkippedHeaders are set by the client for the target rather than
+	// copied from the recording.
+	harSkippedHeaders = map[string]bool{
+		"Connection":     true,
+		"Content-Length": true,
+		"Host":           true,
+	}
+)
+
+type harFile struct {
+	Log struct {
+		Entries []struct {
+			StartedDateTime time.Time `json:"startedDateTime"`
+			Request         struct {
+				Method  string `json:"method"`
+				URL     string `json:"url"`
+				Headers []struct {
+					Name  string `json:"name"`
+					Value string `json:"value"`
+				} `json:"headers"`
+				PostData *struct {
+					MimeType string `json:"mimeType"`
+					Text     string `json:"text"`
+				} `json:"postData"`
+			} `json:"request"`
+		} `json:"entries"`
+	} `json:"log"`
+}
+
+// ReadHAR reads the requests recorded in an HTTP Archive, in the order they
+// were sent. Offsets are relative to the first request.
+func ReadHAR(r io.Reader) ([]Endpoint, error) {
+	har := harFile{}
+	err := json.NewDecoder(r).Decode(&har)
+	if err != nil {
+		return nil, fmt.Errorf("reading HAR: %w", err)
+	}
+	entries := har.Log.Entries
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].StartedDateTime.Before(entries[j].StartedDateTime)
+	})
+	requests := make([]Endpoint, 0, len(entries))
+	for _, entry := range entries {
+		e := Endpoint{
+			Method:  entry.Request.Method,
+			URL:     entry.Request.URL,
+			Headers: map[string]string{},
+			Offset:  entry.StartedDateTime.Sub(entries[0].StartedDateTime),
+		}
+		for _, h := range entry.Request.Headers {
+			key := http.CanonicalHeaderKey(h.Name)
+			if strings.HasPrefix(key, ":") || harSkippedHeaders[key] {
+				continue
+			}
+			if e.Headers[key] != "" {
+				// Cookies are sent in a single header separated by
+				// semicolons, not commas like other repeated headers.
+				separator := ", "
+				if key == "Cookie" {
+					separator = "; "
+				}
+				e.Headers[key] += separator + h.Value
+				continue
+			}
+			e.Headers[key] = h.Value
+		}
+		if entry.Request.PostData != nil {
+			e.Body = entry.Request.PostData.Text
+			if entry.Request.PostData.MimeType != "" {
+				e.Headers["Content-Type"] = entry.Request.PostData.MimeType
+			}
+		}
+		requests = append(requests, e)
+	}
+	return requests, nil
+}
+
+// ReadAccessLog reads the requests of a web server access log in common or
+// combined format. Logs only record the path, so replaying them needs a base
+// URL. Lines without a request, such as timed out connections, are skipped.
+func ReadAccessLog(r io.Reader) ([]Endpoint, error) {
+	requests := []Endpoint{}
+	var first time.Time
+	scanner := bufio.NewScanner(r)
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+		match := accessLogLine.FindStringSubmatch(text)
+		if match == nil {
+			return nil, fmt.Errorf("line %d: invalid access log entry %q", line, text)
+		}
+		sentAt, err := time.Parse(accessLogTimeLayout, match[1])
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		fields := strings.Fields(match[2])
+		if len(fields) < 2 {
+			continue
+		}
+		if len(requests) == 0 {
+			first = sentAt
+		}
+		requests = append(requests, Endpoint{
+			Method: fields[0],
+			URL:    fields[1],
+			Offset: sentAt.Sub(first),
+		})
+	}
+	err := scanner.Err()
+	if err != nil {
+		return nil, err
+	}
+	return requests, nil
+}
+
+// ReadReplayFile reads a HAR file or, when the content is not JSON, an
+// access log.
+func ReadReplayFile(path string) ([]Endpoint, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if bytes.HasPrefix(bytes.TrimSpace(data), []byte("{")) {
+		return ReadHAR(bytes.NewReader(data))
+	}
+	return ReadAccessLog(bytes.NewReader(data))
+}
+
+// WithReplay sends every request once, in order, instead of a URL or
+// scenario. When a URL is also set, requests are sent to it, keeping only
+// their path and query.
+func WithReplay(requests []Endpoint) Option {
+	return func(t *Tester) error {
+		if len(requests) == 0 {
+			return ErrNoReplayRequests
+		}
+		t.replay = requests
+		return nil
+	}
+}
+
+func WithReplayFile(path string) Option {
+	return func(t *Tester) error {
+		requests, err := ReadReplayFile(path)
+		if err != nil {
+			return err
+		}
+		return WithReplay(requests)(t)
+	}
+}
+
+// WithReplaySpeed sends replayed requests at their original offsets divided
+// by speed, so 1 preserves the recorded timing and 2 replays twice as fast.
+// By default requests are sent as fast as the workers allow.
+func WithReplaySpeed(speed float64) Option {
+	return func(t *Tester) error {
+		t.replaySpeed = speed
+		return nil
+	}
+}
+
+func (t Tester) ReplaySpeed() float64 {
+	return t.replaySpeed
+}
+
+// replaySource returns the replayed requests once, in order.
+type replaySource struct {
+	mu        *sync.Mutex
+	next      int
+	endpoints []Endpoint
+}
+
+func (s *replaySource) Next() (Endpoint, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.next >= len(s.endpoints) {
+		return Endpoint{}, false
+	}
+	e := s.endpoints[s.next]
+	s.next++
+	return e, true
+}
+
+// setupReplay points the replayed requests at the base URL, if any, and
+// groups their statistics by method and path.
+func (t *Tester) setupReplay() error {
+	if t.replaySpeed < 0 {
+		return fmt.Errorf("%v is invalid replay speed", t.replaySpeed)
+	}
+	if t.replaySpeed > 0 && t.rate > 0 {
+		return errors.New("replay speed and rate cannot be used together")
+	}
+	var base *url.URL
+	if t.URL != "" {
+		err := validateURL(t.URL)
+		if err != nil {
+			return err
+		}
+		base, _ = url.Parse(t.URL)
+	}
+	for i := range t.replay {
+		e := &t.replay[i]
+		u, err := url.Parse(e.URL)
+		if err != nil {
+			return fmt.Errorf("replay request %d: %w", i, err)
+		}
+		if base != nil {
+			rebased := *base
+			rebased.Path = strings.TrimSuffix(base.Path, "/") + u.Path
+			rebased.RawPath = ""
+			rebased.RawQuery = u.RawQuery
+			u = &rebased
+			e.URL = u.String()
+		}
+		if u.Host == "" {
+			return fmt.Errorf("replay request %d: %q has no host, set a base URL", i, e.URL)
+		}
+		path := u.Path
+		if path == "" {
+			path = "/"
+		}
+		if e.Method == "" {
+			e.Method = http.MethodGet
+		}
+		e.Name = strings.ToUpper(e.Method) + " " + path
+		err = e.setDefaults()
+		if err != nil {
+			return fmt.Errorf("replay request %d: %w", i, err)
+		}
+		_, err = t.endpointStatsFor(*e)
+		if err != nil {
+			return err
+		}
+	}
+	t.requests = len(t.replay)
+	t.source = &replaySource{
+		mu:        &sync.Mutex{},
+		endpoints: t.replay,
+	}
+	return nil
+}
+
+// feedReplay sends one work item per replayed request, at its scaled
+// offset when a replay speed is set, until the requests or the duration run
+// out or the run is aborted.
+func (t *Tester) feedReplay() {
+	defer close(t.Work)
+	var deadline <-chan time.Time
+	if t.duration > 0 {
+		timer := time.NewTimer(t.duration)
+		defer timer.Stop()
+		deadline = timer.C
+	}
+	for _, e := range t.replay {
+		scheduledAt := time.Now()
+		if t.replaySpeed > 0 {
+			scheduledAt = t.startAt.Add(time.Duration(float64(e.Offset) / t.replaySpeed))
+			wait := time.NewTimer(time.Until(scheduledAt))
+			select {
+			case <-wait.C:
+			case <-deadline:
+				wait.Stop()
+				return
+			case <-t.aborted:
+				wait.Stop()
+				return
+			}
+		}
+		select {
+		case t.Work <- scheduledAt:
+		case <-deadline:
+			return
+		case <-t.aborted:
+			return
+		}
+	}
+}
@@ -0,0 +1,211 @@
+package bench_test
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/thiagonache/bench"
+)
+
+func TestReadReplayFileReadsHARInSendOrder(t *testing.T) {
+	t.Parallel()
+	want := []bench.Endpoint{
+		{
+			Method:  http.MethodGet,
+			URL:     "https://example.com/",
+			Headers: map[string]string{},
+		},
+		{
+			Method: http.MethodPost,
+			URL:    "https://example.com/users?source=har",
+			Headers: map[string]string{
+				"Accept":       "application/json",
+				"Content-Type": "application/json",
+			},
+			Body:   `{"name":"bench"}`,
+			Offset: 300 * time.Millisecond,
+		},
+	}
+	got, err := bench.ReadReplayFile("testdata/replay.har")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !cmp.Equal(want, got) {
+		t.Error(cmp.Diff(want, got))
+	}
+}
+
+func TestReadReplayFileJoinsRepeatedHARHeaders(t *testing.T) {
+	t.Parallel()
+	want := []bench.Endpoint{
+		{
+			Method: http.MethodGet,
+			URL:    "https://example.com/account",
+			Headers: map[string]string{
+				"Accept": "text/html, application/json",
+				"Cookie": "session=abc; theme=dark",
+			},
+		},
+	}
+	got, err := bench.ReadReplayFile("testdata/cookies.har")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !cmp.Equal(want, got) {
+		t.Error(cmp.Diff(want, got))
+	}
+}
+
+func TestReadReplayFileReadsAccessLog(t *testing.T) {
+	t.Parallel()
+	want := []bench.Endpoint{
+		{Method: http.MethodGet, URL: "/index.html"},
+		{Method: http.MethodPost, URL: "/users?id=1", Offset: time.Second},
+		{Method: http.MethodGet, URL: "/index.html", Offset: 3 * time.Second},
+	}
+	got, err := bench.ReadReplayFile("testdata/access.log")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !cmp.Equal(want, got) {
+		t.Error(cmp.Diff(want, got))
+	}
+}
+
+func TestReadAccessLogReturnsErrorWithLineNumber(t *testing.T) {
+	t.Parallel()
+	log := `127.0.0.1 - - [01/May/2022:10:00:00 +0000] "GET / HTTP/1.1" 200 1
+not an access log line
+`
+	_, err := bench.ReadAccessLog(strings.NewReader(log))
+	if err == nil {
+		t.Fatal("want error for invalid line")
+	}
+	if !strings.Contains(err.Error(), "line 2") {
+		t.Errorf("want error to mention line 2, got %q", err)
+	}
+}
+
+func TestNewTesterReplayOfAccessLogWithoutBaseURLReturnsError(t *testing.T) {
+	t.Parallel()
+	_, err := bench.NewTester(
+		bench.WithReplayFile("testdata/access.log"),
+		bench.WithStderr(io.Discard),
+	)
+	if err == nil {
+		t.Fatal("want error replaying paths without a base URL")
+	}
+}
+
+func TestNewTesterWithInvalidReplayOptionsReturnsError(t *testing.T) {
+	t.Parallel()
+	requests := []bench.Endpoint{{URL: "https://example.com/"}}
+	options := map[string][]bench.Option{
+		"speed":    {bench.WithReplay(requests), bench.WithReplaySpeed(-1)},
+		"rate":     {bench.WithReplay(requests), bench.WithReplaySpeed(1), bench.WithRate(10)},
+		"scenario": {bench.WithReplay(requests), bench.WithScenario(bench.Scenario{Endpoints: requests})},
+		"empty":    {bench.WithReplay(nil)},
+	}
+	for name, opts := range options {
+		_, err := bench.NewTester(append(opts, bench.WithStderr(io.Discard))...)
+		if err == nil {
+			t.Errorf("%s: want error", name)
+		}
+	}
+}
+
+func TestRunReplaySendsEveryRequestToBaseURLWithStatsPerPath(t *testing.T) {
+	t.Parallel()
+	var mu sync.Mutex
+	got := []string{}
+	server := httptest.NewTLSServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		got = append(got, r.Method+" "+r.URL.RequestURI())
+		mu.Unlock()
+	}))
+	tester, err := bench.NewTester(
+		bench.WithReplayFile("testdata/access.log"),
+		bench.WithURL(server.URL+"/api/"),
+		bench.WithHTTPClient(server.Client()),
+		bench.WithStdout(io.Discard),
+		bench.WithStderr(io.Discard),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = tester.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"GET /api/index.html",
+		"POST /api/users?id=1",
+		"GET /api/index.html",
+	}
+	if !cmp.Equal(want, got) {
+		t.Error(cmp.Diff(want, got))
+	}
+	stats := tester.Stats()
+	if stats.Requests != 3 {
+		t.Errorf("want 3 requests, got %d", stats.Requests)
+	}
+	endpoints := map[string]int{}
+	for _, e := range stats.Endpoints {
+		endpoints[e.Endpoint] = e.Requests
+	}
+	wantEndpoints := map[string]int{
+		"GET /api/index.html": 2,
+		"POST /api/users":     1,
+	}
+	if !cmp.Equal(wantEndpoints, endpoints) {
+		t.Error(cmp.Diff(wantEndpoints, endpoints))
+	}
+}
+
+func TestRunReplayWithSpeedPreservesScaledTiming(t *testing.T) {
+	t.Parallel()
+	server := httptest.NewTLSServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {}))
+	tester, err := bench.NewTester(
+		bench.WithReplay([]bench.Endpoint{
+			{URL: server.URL + "/a"},
+			{URL: server.URL + "/b", Offset: 400 * time.Millisecond},
+		}),
+		bench.WithReplaySpeed(2),
+		bench.WithHTTPClient(server.Client()),
+		bench.WithStdout(io.Discard),
+		bench.WithStderr(io.Discard),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = tester.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tester.EndAt < 200*time.Millisecond || tester.EndAt > 400*time.Millisecond {
+		t.Errorf("want run of about 200ms replaying at twice the speed, got %v", tester.EndAt)
+	}
+}
+
+func TestFromArgsReplayFlagsConfigureReplay(t *testing.T) {
+	t.Parallel()
+	tester, err := bench.NewTester(
+		bench.FromArgs([]string{"run", "-replay", "testdata/replay.har", "-replay-speed", "1.5"}),
+		bench.WithStderr(io.Discard),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tester.Requests() != 2 {
+		t.Errorf("want 2 requests to replay, got %d", tester.Requests())
+	}
+	if tester.ReplaySpeed() != 1.5 {
+		t.Errorf("want replay speed 1.5, got %v", tester.ReplaySpeed())
+	}
+}
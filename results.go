@@ -0,0 +1,414 @@
+package bench
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const FormatJSONL = "jsonl"
+
+var (
+	ErrNoResults = errors.New("no results")
+
+	resultsHeader = []string{"time", "worker", "endpoint", "method", "url", "status", "latency_ms", "request_bytes", "response_bytes", "error", "assertion"}
+)
+
+// Result is the outcome of one request as written to a result log. Status is
+// zero when no response was received and Error is the class of the failure,
+// empty on success. Assertion is the reason of a failed assertion.
+type Result struct {
+	Time          time.Time
+	Worker        int
+	Endpoint      string
+	Method        string
+	URL           string
+	Status        int
+	Latency       time.Duration
+	RequestBytes  int64
+	ResponseBytes int64
+	Error         string
+	Assertion     string
+}
+
+// resultJSON is a Result as written to JSON Lines, with latency in
+// milliseconds like the rest of the exports.
+type resultJSON struct {
+	Time          time.Time `json:"time"`
+	Worker        int       `json:"worker"`
+	Endpoint      string    `json:"endpoint"`
+	Method        string    `json:"method"`
+	URL           string    `json:"url"`
+	Status        int       `json:"status"`
+	Latency       float64   `json:"latency_ms"`
+	RequestBytes  int64     `json:"request_bytes"`
+	ResponseBytes int64     `json:"response_bytes"`
+	Error         string    `json:"error,omitempty"`
+	Assertion     string    `json:"assertion,omitempty"`
+}
+
+// WithResultLog writes the result of every request to w as it completes,
+// in FormatJSONL or FormatCSV.
+func WithResultLog(w io.Writer, format string) Option {
+	return func(t *Tester) error {
+		if w == nil {
+			return ErrValueCannotBeNil
+		}
+		if format != FormatJSONL && format != FormatCSV {
+			return fmt.Errorf("%q is invalid result log format, want %s or %s", format, FormatJSONL, FormatCSV)
+		}
+		t.resultLogWriter = w
+		t.resultLogFormat = format
+		return nil
+	}
+}
+
+// WithResultLogFile writes the result of every request to path, created when
+// the run starts, as CSV if its extension is .csv and JSON Lines otherwise.
+func WithResultLogFile(path string) Option {
+	return func(t *Tester) error {
+		t.resultLogPath = path
+		t.resultLogFormat = FormatJSONL
+		if strings.HasSuffix(path, ".csv") {
+			t.resultLogFormat = FormatCSV
+		}
+		return nil
+	}
+}
+
+// WithResults computes the statistics, graphs and exports of a previous run
+// from its results instead of sending requests.
+func WithResults(results []Result) Option {
+	return func(t *Tester) error {
+		if len(results) == 0 {
+			return ErrNoResults
+		}
+		t.results = results
+		return nil
+	}
+}
+
+func WithResultsFile(path string) Option {
+	return func(t *Tester) error {
+		results, err := ReadResultsFile(path)
+		if err != nil {
+			return err
+		}
+		return WithResults(results)(t)
+	}
+}
+
+// ReadResults reads a result log written as JSON Lines or CSV.
+func ReadResults(r io.Reader) ([]Result, error) {
+	br := bufio.NewReader(r)
+	start, err := br.Peek(1)
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	if bytes.Equal(start, []byte("{")) {
+		return readResultsJSONL(br)
+	}
+	return readResultsCSV(br)
+}
+
+func ReadResultsFile(path string) ([]Result, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ReadResults(f)
+}
+
+func readResultsJSONL(r io.Reader) ([]Result, error) {
+	results := []Result{}
+	scanner := bufio.NewScanner(r)
+	line := 0
+	for scanner.Scan() {
+		line++
+		if strings.TrimSpace(scanner.Text()) == "" {
+			continue
+		}
+		res := resultJSON{}
+		err := json.Unmarshal(scanner.Bytes(), &res)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		results = append(results, Result{
+			Time:          res.Time,
+			Worker:        res.Worker,
+			Endpoint:      res.Endpoint,
+			Method:        res.Method,
+			URL:           res.URL,
+			Status:        res.Status,
+			Latency:       fromMilliseconds(res.Latency),
+			RequestBytes:  res.RequestBytes,
+			ResponseBytes: res.ResponseBytes,
+			Error:         res.Error,
+			Assertion:     res.Assertion,
+		})
+	}
+	err := scanner.Err()
+	if err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// readResultsCSV reads a CSV result log, including those written before the
+// assertion column was added.
+func readResultsCSV(r io.Reader) ([]Result, error) {
+	cr := csv.NewReader(r)
+	header, err := cr.Read()
+	if err != nil {
+		return nil, fmt.Errorf("line 1: %w", err)
+	}
+	if strings.Join(header, ",") != strings.Join(resultsHeader, ",") &&
+		strings.Join(header, ",") != strings.Join(resultsHeader[:len(resultsHeader)-1], ",") {
+		return nil, fmt.Errorf("line 1: invalid header %q, want %q", strings.Join(header, ","), strings.Join(resultsHeader, ","))
+	}
+	results := []Result{}
+	for line := 2; ; line++ {
+		record, err := cr.Read()
+		if err == io.EOF {
+			return results, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		res, err := parseResult(record)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		results = append(results, res)
+	}
+}
+
+func parseResult(record []string) (Result, error) {
+	res := Result{
+		Endpoint: record[2],
+		Method:   record[3],
+		URL:      record[4],
+		Error:    record[9],
+	}
+	if len(record) == len(resultsHeader) {
+		res.Assertion = record[10]
+	}
+	var err error
+	res.Time, err = time.Parse(time.RFC3339Nano, record[0])
+	if err != nil {
+		return Result{}, err
+	}
+	res.Worker, err = strconv.Atoi(record[1])
+	if err != nil {
+		return Result{}, fmt.Errorf("invalid worker %q", record[1])
+	}
+	res.Status, err = strconv.Atoi(record[5])
+	if err != nil {
+		return Result{}, fmt.Errorf("invalid status %q", record[5])
+	}
+	latency, err := strconv.ParseFloat(record[6], 64)
+	if err != nil {
+		return Result{}, fmt.Errorf("invalid latency %q", record[6])
+	}
+	res.Latency = fromMilliseconds(latency)
+	res.RequestBytes, err = strconv.ParseInt(record[7], 10, 64)
+	if err != nil {
+		return Result{}, fmt.Errorf("invalid request bytes %q", record[7])
+	}
+	res.ResponseBytes, err = strconv.ParseInt(record[8], 10, 64)
+	if err != nil {
+		return Result{}, fmt.Errorf("invalid response bytes %q", record[8])
+	}
+	return res, nil
+}
+
+func (r result) toResult() Result {
+	sentAt := r.startTime
+	if sentAt.IsZero() {
+		sentAt = r.scheduledAt
+	}
+	res := Result{
+		Time:          sentAt,
+		Worker:        r.worker,
+		Endpoint:      r.endpoint.Name,
+		Method:        r.endpoint.Method,
+		URL:           r.endpoint.URL,
+		Status:        r.statusCode,
+		RequestBytes:  r.sent,
+		ResponseBytes: r.received,
+		Error:         r.class,
+	}
+	if r.responded {
+		res.Latency = r.elapsed()
+	}
+	assertionErr := &AssertionError{}
+	if errors.As(r.err, &assertionErr) {
+		res.Assertion = assertionErr.Reason
+	}
+	return res
+}
+
+func fromMilliseconds(ms float64) time.Duration {
+	return time.Duration(ms * float64(time.Millisecond))
+}
+
+// resultLog writes results from a buffered channel, so that workers are not
+// held up by the file.
+type resultLog struct {
+	results chan Result
+	done    chan struct{}
+	err     error
+}
+
+func (t *Tester) startResultLog() (*resultLog, error) {
+	w := t.resultLogWriter
+	var file *os.File
+	if t.resultLogPath != "" {
+		var err error
+		file, err = os.Create(t.resultLogPath)
+		if err != nil {
+			return nil, err
+		}
+		w = file
+	}
+	l := &resultLog{
+		results: make(chan Result, 1024),
+		done:    make(chan struct{}),
+	}
+	go func() {
+		defer close(l.done)
+		bw := bufio.NewWriter(w)
+		write := l.writeJSONL
+		if t.resultLogFormat == FormatCSV {
+			write = l.writeCSV
+		}
+		l.err = write(bw)
+		if l.err == nil {
+			l.err = bw.Flush()
+		}
+		if file != nil {
+			err := file.Close()
+			if l.err == nil {
+				l.err = err
+			}
+		}
+	}()
+	return l, nil
+}
+
+// stop waits for every result to be written, returning the first error.
+func (l *resultLog) stop() error {
+	close(l.results)
+	<-l.done
+	return l.err
+}
+
+func (l *resultLog) writeJSONL(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	var err error
+	for res := range l.results {
+		if err == nil {
+			err = enc.Encode(resultJSON{
+				Time:          res.Time,
+				Worker:        res.Worker,
+				Endpoint:      res.Endpoint,
+				Method:        res.Method,
+				URL:           res.URL,
+				Status:        res.Status,
+				Latency:       milliseconds(res.Latency),
+				RequestBytes:  res.RequestBytes,
+				ResponseBytes: res.ResponseBytes,
+				Error:         res.Error,
+				Assertion:     res.Assertion,
+			})
+		}
+	}
+	return err
+}
+
+func (l *resultLog) writeCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	err := cw.Write(resultsHeader)
+	for res := range l.results {
+		if err != nil {
+			continue
+		}
+		err = cw.Write([]string{
+			res.Time.Format(time.RFC3339Nano),
+			strconv.Itoa(res.Worker),
+			res.Endpoint,
+			res.Method,
+			res.URL,
+			strconv.Itoa(res.Status),
+			strconv.FormatFloat(milliseconds(res.Latency), 'f', -1, 64),
+			strconv.FormatInt(res.RequestBytes, 10),
+			strconv.FormatInt(res.ResponseBytes, 10),
+			res.Error,
+			res.Assertion,
+		})
+	}
+	if err != nil {
+		return err
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// loadResults records the results of a previous run as if they had just
+// been received, timing the run from the first request sent to the last
+// response.
+func (t *Tester) loadResults() {
+	t.startAt = t.results[0].Time
+	end := t.startAt
+	for _, res := range t.results {
+		if res.Time.Before(t.startAt) {
+			t.startAt = res.Time
+		}
+		if res.Time.Add(res.Latency).After(end) {
+			end = res.Time.Add(res.Latency)
+		}
+	}
+	t.EndAt = end.Sub(t.startAt)
+	for _, res := range t.results {
+		r := result{
+			endpoint: Endpoint{
+				Name:   res.Endpoint,
+				Method: res.Method,
+				URL:    res.URL,
+			},
+			worker:      res.Worker,
+			scheduledAt: res.Time,
+			startTime:   res.Time,
+			bodyDone:    res.Time.Add(res.Latency),
+			timer:       &phaseTimer{},
+			responded:   res.Status != 0,
+			statusCode:  res.Status,
+			sent:        res.RequestBytes,
+			received:    res.ResponseBytes,
+			class:       res.Error,
+		}
+		switch {
+		case res.Assertion != "":
+			r.err = &AssertionError{
+				Reason:     res.Assertion,
+				Detail:     res.Assertion,
+				StatusCode: res.Status,
+			}
+		case res.Error != "":
+			r.err = errors.New(res.Error)
+		}
+		err := t.record(r)
+		if err != nil && r.err == nil {
+			t.LogFStdErr("%v\n", err)
+		}
+	}
+}
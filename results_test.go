@@ -0,0 +1,276 @@
+package bench_test
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"github.com/thiagonache/bench"
+)
+
+func TestRun_LogsResultOfEveryRequest(t *testing.T) {
+	t.Parallel()
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/missing" {
+			rw.WriteHeader(http.StatusNotFound)
+			return
+		}
+		rw.Write([]byte("hello"))
+	}))
+	defer server.Close()
+	for _, format := range []string{bench.FormatJSONL, bench.FormatCSV} {
+		buf := &bytes.Buffer{}
+		tester, err := bench.NewTester(
+			bench.WithScenario(bench.Scenario{
+				Order: bench.OrderRoundRobin,
+				Endpoints: []bench.Endpoint{
+					{URL: server.URL + "/ok"},
+					{URL: server.URL + "/missing"},
+				},
+			}),
+			bench.WithRequests(4),
+			bench.WithResultLog(buf, format),
+			bench.WithStdout(io.Discard),
+			bench.WithStderr(io.Discard),
+		)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = tester.Run()
+		if err != nil {
+			t.Fatal(err)
+		}
+		results, err := bench.ReadResults(buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(results) != 4 {
+			t.Fatalf("%s: want 4 results, got %d", format, len(results))
+		}
+		got := map[string]int{}
+		for _, r := range results {
+			if r.Time.IsZero() || r.Latency <= 0 || r.Method != http.MethodGet || r.Worker != 0 {
+				t.Errorf("%s: want time, latency, method and worker logged, got %+v", format, r)
+			}
+			got[r.URL+" "+r.Error]++
+			if r.Status == http.StatusOK && r.ResponseBytes != 5 {
+				t.Errorf("%s: want 5 response bytes, got %d", format, r.ResponseBytes)
+			}
+		}
+		want := map[string]int{
+			server.URL + "/ok ":                     2,
+			server.URL + "/missing " + "status 404": 2,
+		}
+		if !cmp.Equal(want, got) {
+			t.Errorf("%s: %s", format, cmp.Diff(want, got))
+		}
+	}
+}
+
+func TestRun_RegeneratesStatsFromLoadedResults(t *testing.T) {
+	t.Parallel()
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		rw.Write([]byte("hello"))
+	}))
+	defer server.Close()
+	path := t.TempDir() + "/results.csv"
+	original, err := bench.NewTester(
+		bench.WithURL(server.URL),
+		bench.WithRequests(20),
+		bench.WithConcurrency(2),
+		bench.WithResultLogFile(path),
+		bench.WithStdout(io.Discard),
+		bench.WithStderr(io.Discard),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = original.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	loaded, err := bench.NewTester(
+		bench.WithResultsFile(path),
+		bench.WithGraphs(true),
+		bench.WithOutputPath(dir),
+		bench.WithStdout(io.Discard),
+		bench.WithStderr(io.Discard),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = loaded.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, got := original.Stats(), loaded.Stats()
+	ignore := cmpopts.IgnoreFields(bench.Stats{}, "URL", "Duration", "Phases")
+	if !cmp.Equal(want, got, ignore, cmpopts.EquateApprox(0, 0.001)) {
+		t.Error(cmp.Diff(want, got, ignore, cmpopts.EquateApprox(0, 0.001)))
+	}
+	if got.Duration <= 0 || got.Duration > want.Duration {
+		t.Errorf("want duration of loaded run between 0 and %v, got %v", want.Duration, got.Duration)
+	}
+	_, err = os.Stat(dir + "/boxplot.png")
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+func TestRun_RestoresAssertionFailuresFromLoadedResults(t *testing.T) {
+	t.Parallel()
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/missing":
+			rw.WriteHeader(http.StatusNotFound)
+		case "/bye":
+			rw.Write([]byte("bye"))
+		default:
+			rw.Write([]byte("hello"))
+		}
+	}))
+	defer server.Close()
+	for _, format := range []string{bench.FormatJSONL, bench.FormatCSV} {
+		path := t.TempDir() + "/results." + format
+		original, err := bench.NewTester(
+			bench.WithScenario(bench.Scenario{
+				Order: bench.OrderRoundRobin,
+				Endpoints: []bench.Endpoint{
+					{URL: server.URL + "/ok"},
+					{URL: server.URL + "/missing"},
+					{URL: server.URL + "/bye"},
+				},
+			}),
+			bench.WithRequests(6),
+			bench.WithBodyContains("hello"),
+			bench.WithResultLogFile(path),
+			bench.WithStdout(io.Discard),
+			bench.WithStderr(io.Discard),
+		)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = original.Run()
+		if err != nil {
+			t.Fatal(err)
+		}
+		loaded, err := bench.NewTester(
+			bench.WithResultsFile(path),
+			bench.WithOutputPath(t.TempDir()),
+			bench.WithStdout(io.Discard),
+			bench.WithStderr(io.Discard),
+		)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = loaded.Run()
+		if err != nil {
+			t.Fatal(err)
+		}
+		want, got := original.Stats(), loaded.Stats()
+		if len(want.AssertionFailures) != 2 {
+			t.Fatalf("%s: want status and body assertion failures, got %v", format, want.AssertionFailures)
+		}
+		if !cmp.Equal(want.AssertionFailures, got.AssertionFailures) {
+			t.Errorf("%s: %s", format, cmp.Diff(want.AssertionFailures, got.AssertionFailures))
+		}
+		if !cmp.Equal(want.Errors, got.Errors) {
+			t.Errorf("%s: %s", format, cmp.Diff(want.Errors, got.Errors))
+		}
+	}
+}
+
+func TestReadResults_ReadsCSVWithoutAssertionColumn(t *testing.T) {
+	t.Parallel()
+	input := "time,worker,endpoint,method,url,status,latency_ms,request_bytes,response_bytes,error\n" +
+		"2022-03-01T10:00:00Z,1,GET /,GET,http://example.com/,404,12.5,0,10,status 404\n"
+	want := []bench.Result{{
+		Time:          time.Date(2022, 3, 1, 10, 0, 0, 0, time.UTC),
+		Worker:        1,
+		Endpoint:      "GET /",
+		Method:        http.MethodGet,
+		URL:           "http://example.com/",
+		Status:        http.StatusNotFound,
+		Latency:       12500 * time.Microsecond,
+		ResponseBytes: 10,
+		Error:         "status 404",
+	}}
+	got, err := bench.ReadResults(strings.NewReader(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !cmp.Equal(want, got) {
+		t.Error(cmp.Diff(want, got))
+	}
+}
+
+func TestReadResults_ReadsJSONLines(t *testing.T) {
+	t.Parallel()
+	input := `{"time":"2022-03-01T10:00:00Z","worker":1,"endpoint":"GET /","method":"GET","url":"http://example.com/","status":200,"latency_ms":12.5,"request_bytes":0,"response_bytes":10}
+{"time":"2022-03-01T10:00:01Z","worker":2,"endpoint":"GET /","method":"GET","url":"http://example.com/","status":0,"latency_ms":0,"request_bytes":0,"response_bytes":0,"error":"timeout"}
+`
+	want := []bench.Result{
+		{
+			Time:          time.Date(2022, 3, 1, 10, 0, 0, 0, time.UTC),
+			Worker:        1,
+			Endpoint:      "GET /",
+			Method:        http.MethodGet,
+			URL:           "http://example.com/",
+			Status:        http.StatusOK,
+			Latency:       12500 * time.Microsecond,
+			ResponseBytes: 10,
+		},
+		{
+			Time:     time.Date(2022, 3, 1, 10, 0, 1, 0, time.UTC),
+			Worker:   2,
+			Endpoint: "GET /",
+			Method:   http.MethodGet,
+			URL:      "http://example.com/",
+			Error:    bench.ErrorClassTimeout,
+		},
+	}
+	got, err := bench.ReadResults(strings.NewReader(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !cmp.Equal(want, got) {
+		t.Error(cmp.Diff(want, got))
+	}
+}
+
+func TestReadResults_ErrorsWithLineNumber(t *testing.T) {
+	t.Parallel()
+	tcs := map[string]string{
+		"line 2": `{"time":"2022-03-01T10:00:00Z"}` + "\n{bogus\n",
+		"line 1": "bogus,header\n",
+		"line 3": "time,worker,endpoint,method,url,status,latency_ms,request_bytes,response_bytes,error\n" +
+			"2022-03-01T10:00:00Z,1,GET /,GET,http://example.com/,200,12.5,0,10,\n" +
+			"2022-03-01T10:00:00Z,1,GET /,GET,http://example.com/,ok,12.5,0,10,\n",
+	}
+	for want, input := range tcs {
+		_, err := bench.ReadResults(strings.NewReader(input))
+		if err == nil {
+			t.Errorf("want error for %q", input)
+			continue
+		}
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("want error mentioning %q, got %q", want, err)
+		}
+	}
+}
+
+func TestWithResults_ErrorsIfNoResults(t *testing.T) {
+	t.Parallel()
+	_, err := bench.NewTester(bench.WithResults(nil))
+	if err == nil {
+		t.Error("want error")
+	}
+}
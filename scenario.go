@@ -0,0 +1,200 @@
+package bench
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	OrderRandom     = "random"
+	OrderRoundRobin = "round-robin"
+)
+
+// Endpoint is a single request of a scenario. Weight is relative to the
+// other endpoints and defaults to 1. Offset is when a replayed request was
+// originally sent, relative to the first one.
+type Endpoint struct {
+	Name    string            `yaml:"name"`
+	Method  string            `yaml:"method"`
+	URL     string            `yaml:"url"`
+	Headers map[string]string `yaml:"headers"`
+	Body    string            `yaml:"body"`
+	Weight  int               `yaml:"weight"`
+	Offset  time.Duration     `yaml:"-"`
+}
+
+// Scenario is a list of weighted endpoints that workers pick from, either
+// at random or round-robin. A plain URL is a one-endpoint scenario.
+type Scenario struct {
+	Order     string     `yaml:"order"`
+	Endpoints []Endpoint `yaml:"requests"`
+}
+
+// ReadScenario decodes a YAML scenario. JSON is valid YAML, so JSON
+// scenarios are read as well.
+func ReadScenario(r io.Reader) (Scenario, error) {
+	scenario := Scenario{}
+	dec := yaml.NewDecoder(r)
+	dec.KnownFields(true)
+	err := dec.Decode(&scenario)
+	if err != nil {
+		return Scenario{}, fmt.Errorf("reading scenario: %w", err)
+	}
+	return scenario, nil
+}
+
+func ReadScenarioFile(path string) (Scenario, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return Scenario{}, err
+	}
+	defer f.Close()
+	return ReadScenario(f)
+}
+
+func WithScenario(scenario Scenario) Option {
+	return func(t *Tester) error {
+		t.scenario = scenario
+		return nil
+	}
+}
+
+func WithScenarioFile(path string) Option {
+	return func(t *Tester) error {
+		scenario, err := ReadScenarioFile(path)
+		if err != nil {
+			return err
+		}
+		t.scenario = scenario
+		return nil
+	}
+}
+
+func (t Tester) Scenario() Scenario {
+	return t.scenario
+}
+
+func (s *Scenario) setDefaults() error {
+	switch s.Order {
+	case "":
+		s.Order = OrderRandom
+	case OrderRandom, OrderRoundRobin:
+	default:
+		return fmt.Errorf("invalid scenario order %q, want %q or %q", s.Order, OrderRandom, OrderRoundRobin)
+	}
+	for i := range s.Endpoints {
+		err := s.Endpoints[i].setDefaults()
+		if err != nil {
+			return fmt.Errorf("scenario request %d: %w", i, err)
+		}
+	}
+	return nil
+}
+
+func (e *Endpoint) setDefaults() error {
+	if e.URL == "" {
+		return ErrNoURL
+	}
+	err := validateURL(e.URL)
+	if err != nil {
+		return err
+	}
+	if e.Method == "" {
+		e.Method = http.MethodGet
+	}
+	e.Method = strings.ToUpper(e.Method)
+	_, err = http.NewRequest(e.Method, e.URL, nil)
+	if err != nil {
+		return err
+	}
+	if e.Weight < 0 {
+		return fmt.Errorf("%d is invalid weight", e.Weight)
+	}
+	if e.Weight == 0 {
+		e.Weight = 1
+	}
+	if e.Name == "" {
+		e.Name = e.Method + " " + e.URL
+	}
+	return nil
+}
+
+func validateURL(rawURL string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return err
+	}
+	if u.Host == "" {
+		return fmt.Errorf("invalid URL %q", u)
+	}
+	return nil
+}
+
+// scenarioSource picks scenario endpoints at random, in proportion to their
+// weights, or round-robin. It never runs out of requests. Random picks come
+// from the seed of the run, so a run with one worker is reproducible.
+type scenarioSource struct {
+	mu    *sync.Mutex
+	next  int
+	order string
+	rand  splitMix64
+	// cumulative holds the running total of the weights, so that a number
+	// below the total of every weight picks the first endpoint whose total
+	// is over it.
+	cumulative []int
+	endpoints  []Endpoint
+}
+
+func newScenarioSource(s Scenario, seed int64) *scenarioSource {
+	src := &scenarioSource{
+		mu:         &sync.Mutex{},
+		order:      s.Order,
+		rand:       splitMix64(mix64(uint64(seed))),
+		cumulative: make([]int, len(s.Endpoints)),
+		endpoints:  s.Endpoints,
+	}
+	total := 0
+	for i, e := range s.Endpoints {
+		total += e.Weight
+		src.cumulative[i] = total
+	}
+	return src
+}
+
+func (s *scenarioSource) Next() (Endpoint, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	total := s.cumulative[len(s.cumulative)-1]
+	var n int
+	if s.order == OrderRoundRobin {
+		n = s.next % total
+		s.next++
+	} else {
+		n = int(s.rand.next() % uint64(total))
+	}
+	return s.endpoints[sort.SearchInts(s.cumulative, n+1)], true
+}
+
+func (t *Tester) setupScenario() error {
+	err := t.scenario.setDefaults()
+	if err != nil {
+		return err
+	}
+	for _, e := range t.scenario.Endpoints {
+		_, err := t.endpointStatsFor(e)
+		if err != nil {
+			return err
+		}
+	}
+	t.source = newScenarioSource(t.scenario, t.seed)
+	return nil
+}
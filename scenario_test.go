@@ -0,0 +1,302 @@
+package bench_test
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/thiagonache/bench"
+)
+
+func TestReadScenarioFileReadsYAMLAndJSON(t *testing.T) {
+	t.Parallel()
+	want := bench.Scenario{
+		Order: bench.OrderRoundRobin,
+		Endpoints: []bench.Endpoint{
+			{
+				Name:   "home",
+				URL:    "https://example.com/",
+				Weight: 3,
+			},
+			{
+				Name:   "create user",
+				Method: "post",
+				URL:    "https://example.com/users",
+				Headers: map[string]string{
+					"Content-Type": "application/json",
+				},
+				Body: `{"name":"bench"}`,
+			},
+		},
+	}
+	for _, path := range []string{"testdata/scenario.yaml", "testdata/scenario.json"} {
+		got, err := bench.ReadScenarioFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !cmp.Equal(want, got) {
+			t.Errorf("%s: %s", path, cmp.Diff(want, got))
+		}
+	}
+}
+
+func TestReadScenarioRejectsUnknownFields(t *testing.T) {
+	t.Parallel()
+	_, err := bench.ReadScenario(strings.NewReader("requests:\n  - uri: https://example.com\n"))
+	if err == nil {
+		t.Fatal("want error for unknown field")
+	}
+}
+
+func TestNewTesterWithScenarioSetsDefaults(t *testing.T) {
+	t.Parallel()
+	tester, err := bench.NewTester(
+		bench.WithScenario(bench.Scenario{
+			Endpoints: []bench.Endpoint{
+				{URL: "https://example.com/"},
+				{Method: "put", URL: "https://example.com/users/1", Weight: 2},
+			},
+		}),
+		bench.WithStderr(io.Discard),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := bench.Scenario{
+		Order: bench.OrderRandom,
+		Endpoints: []bench.Endpoint{
+			{
+				Name:   "GET https://example.com/",
+				Method: http.MethodGet,
+				URL:    "https://example.com/",
+				Weight: 1,
+			},
+			{
+				Name:   "PUT https://example.com/users/1",
+				Method: http.MethodPut,
+				URL:    "https://example.com/users/1",
+				Weight: 2,
+			},
+		},
+	}
+	got := tester.Scenario()
+	if !cmp.Equal(want, got) {
+		t.Error(cmp.Diff(want, got))
+	}
+}
+
+func TestNewTesterWithInvalidScenarioReturnsError(t *testing.T) {
+	t.Parallel()
+	scenarios := map[string]bench.Scenario{
+		"order":  {Order: "sequential", Endpoints: []bench.Endpoint{{URL: "https://example.com"}}},
+		"no URL": {Endpoints: []bench.Endpoint{{Method: http.MethodGet}}},
+		"URL":    {Endpoints: []bench.Endpoint{{URL: "example.com"}}},
+		"method": {Endpoints: []bench.Endpoint{{Method: "BAD METHOD", URL: "https://example.com"}}},
+		"weight": {Endpoints: []bench.Endpoint{{URL: "https://example.com", Weight: -1}}},
+	}
+	for name, scenario := range scenarios {
+		_, err := bench.NewTester(
+			bench.WithScenario(scenario),
+			bench.WithStderr(io.Discard),
+		)
+		if err == nil {
+			t.Errorf("%s: want error for invalid scenario", name)
+		}
+	}
+}
+
+func TestNewTesterWithURLIsOneEndpointScenario(t *testing.T) {
+	t.Parallel()
+	tester, err := bench.NewTester(
+		bench.WithURL("https://example.com"),
+		bench.WithHTTPMethod(http.MethodPost),
+		bench.WithBody([]byte("hello")),
+		bench.WithStderr(io.Discard),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []bench.Endpoint{
+		{
+			Name:   "POST https://example.com",
+			Method: http.MethodPost,
+			URL:    "https://example.com",
+			Body:   "hello",
+			Weight: 1,
+		},
+	}
+	got := tester.Scenario().Endpoints
+	if !cmp.Equal(want, got) {
+		t.Error(cmp.Diff(want, got))
+	}
+}
+
+func TestScenarioSourceRoundRobinFollowsWeights(t *testing.T) {
+	t.Parallel()
+	tester, err := bench.NewTester(
+		bench.WithScenario(bench.Scenario{
+			Order: bench.OrderRoundRobin,
+			Endpoints: []bench.Endpoint{
+				{Name: "a", URL: "https://example.com/a", Weight: 2},
+				{Name: "b", URL: "https://example.com/b"},
+			},
+		}),
+		bench.WithStderr(io.Discard),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"a", "a", "b", "a", "a", "b"}
+	got := []string{}
+	for range want {
+		e, ok := tester.RequestSource().Next()
+		if !ok {
+			t.Fatal("want scenario source never to run out of requests")
+		}
+		got = append(got, e.Name)
+	}
+	if !cmp.Equal(want, got) {
+		t.Error(cmp.Diff(want, got))
+	}
+}
+
+func TestScenarioSourceRandomPicksEveryEndpoint(t *testing.T) {
+	t.Parallel()
+	tester, err := bench.NewTester(
+		bench.WithScenario(bench.Scenario{
+			Endpoints: []bench.Endpoint{
+				{URL: "https://example.com/a"},
+				{URL: "https://example.com/b"},
+			},
+		}),
+		bench.WithStderr(io.Discard),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	seen := map[string]bool{}
+	for i := 0; i < 1000; i++ {
+		e, _ := tester.RequestSource().Next()
+		seen[e.URL] = true
+	}
+	if !seen["https://example.com/a"] || !seen["https://example.com/b"] {
+		t.Errorf("want both endpoints picked, got %v", seen)
+	}
+}
+
+func TestScenarioSourceRandomPicksAreReproducibleForSeed(t *testing.T) {
+	t.Parallel()
+	picks := func(seed int64) []string {
+		tester, err := bench.NewTester(
+			bench.WithScenario(bench.Scenario{
+				Endpoints: []bench.Endpoint{
+					{Name: "a", URL: "https://example.com/a", Weight: 1 << 29},
+					{Name: "b", URL: "https://example.com/b", Weight: 1 << 29},
+					{Name: "c", URL: "https://example.com/c", Weight: 1},
+				},
+			}),
+			bench.WithSeed(seed),
+			bench.WithStderr(io.Discard),
+		)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := []string{}
+		for i := 0; i < 50; i++ {
+			e, _ := tester.RequestSource().Next()
+			got = append(got, e.Name)
+		}
+		return got
+	}
+	first, second := picks(42), picks(42)
+	if !cmp.Equal(first, second) {
+		t.Error(cmp.Diff(first, second))
+	}
+	if cmp.Equal(first, picks(7)) {
+		t.Error("want different picks for a different seed")
+	}
+	counts := map[string]int{}
+	for _, name := range first {
+		counts[name]++
+	}
+	if counts["a"] == 0 || counts["b"] == 0 || counts["c"] != 0 {
+		t.Errorf("want picks in proportion to weights, got %v", counts)
+	}
+}
+
+func TestRunWithScenarioReportsStatsPerEndpoint(t *testing.T) {
+	t.Parallel()
+	var mu sync.Mutex
+	got := map[string]int{}
+	server := httptest.NewTLSServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		got[r.Method+" "+r.URL.Path+" "+r.Header.Get("X-Endpoint")]++
+		mu.Unlock()
+		if r.URL.Path == "/fail" {
+			rw.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	tester, err := bench.NewTester(
+		bench.WithScenario(bench.Scenario{
+			Order: bench.OrderRoundRobin,
+			Endpoints: []bench.Endpoint{
+				{Name: "ok", URL: server.URL + "/ok", Weight: 3},
+				{
+					Name:    "fail",
+					Method:  http.MethodPost,
+					URL:     server.URL + "/fail",
+					Headers: map[string]string{"X-Endpoint": "fail"},
+					Body:    "data",
+				},
+			},
+		}),
+		bench.WithRequests(20),
+		bench.WithConcurrency(1),
+		bench.WithHTTPClient(server.Client()),
+		bench.WithStdout(io.Discard),
+		bench.WithStderr(io.Discard),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = tester.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stats := tester.Stats()
+	if len(stats.Endpoints) != 2 {
+		t.Fatalf("want stats for 2 endpoints, got %d", len(stats.Endpoints))
+	}
+	ok, fail := stats.Endpoints[0], stats.Endpoints[1]
+	if ok.Endpoint != "ok" || ok.Requests != 15 || ok.Successes != 15 || ok.Failures != 0 {
+		t.Errorf("unexpected stats for ok endpoint: %+v", ok)
+	}
+	if fail.Endpoint != "fail" || fail.Requests != 5 || fail.Failures != 5 {
+		t.Errorf("unexpected stats for fail endpoint: %+v", fail)
+	}
+	if got["POST /fail fail"] != 5 {
+		t.Errorf("want five POSTs to /fail with endpoint header, got %v", got)
+	}
+	if stats.Requests != ok.Requests+fail.Requests {
+		t.Errorf("want aggregate requests %d to be the sum of endpoints, got %d", ok.Requests+fail.Requests, stats.Requests)
+	}
+}
+
+func TestFromArgsScenarioFlagConfiguresScenario(t *testing.T) {
+	t.Parallel()
+	tester, err := bench.NewTester(
+		bench.FromArgs([]string{"run", "-f", "testdata/scenario.yaml"}),
+		bench.WithStderr(io.Discard),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := tester.Scenario()
+	if got.Order != bench.OrderRoundRobin || len(got.Endpoints) != 2 {
+		t.Errorf("want round-robin scenario with 2 endpoints, got %+v", got)
+	}
+}
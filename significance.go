@@ -0,0 +1,81 @@
+package bench
+
+import (
+	"math"
+	"sort"
+)
+
+// DefaultAlpha is the significance level below which comparisons report a
+// change in latency as real rather than noise.
+const DefaultAlpha = 0.05
+
+// Significance is the outcome of a Mann-Whitney U test on the latency
+// samples of two runs. PValue is the probability of a difference at least as
+// large if both samples came from the same distribution.
+type Significance struct {
+	U      float64
+	PValue float64
+	N1, N2 int
+}
+
+// MannWhitneyU tests whether samples1 and samples2 come from the same
+// distribution, using the normal approximation with corrections for ties and
+// continuity, which suits the hundreds of samples a benchmark collects. The
+// p-value is two-sided, and 1 if either set of samples is empty.
+func MannWhitneyU(samples1, samples2 []float64) Significance {
+	n1, n2 := len(samples1), len(samples2)
+	s := Significance{PValue: 1, N1: n1, N2: n2}
+	if n1 == 0 || n2 == 0 {
+		return s
+	}
+	type sample struct {
+		value float64
+		first bool
+	}
+	all := make([]sample, 0, n1+n2)
+	for _, v := range samples1 {
+		all = append(all, sample{v, true})
+	}
+	for _, v := range samples2 {
+		all = append(all, sample{v, false})
+	}
+	sort.Slice(all, func(i, j int) bool {
+		return all[i].value < all[j].value
+	})
+	rankSum, ties := 0.0, 0.0
+	for i := 0; i < len(all); {
+		j := i
+		for j < len(all) && all[j].value == all[i].value {
+			j++
+		}
+		// Tied values share the mean of the ranks i+1 to j.
+		rank := float64(i+j+1) / 2
+		for k := i; k < j; k++ {
+			if all[k].first {
+				rankSum += rank
+			}
+		}
+		tied := float64(j - i)
+		ties += tied*tied*tied - tied
+		i = j
+	}
+	s.U = rankSum - float64(n1*(n1+1))/2
+	n := float64(n1 + n2)
+	mean := float64(n1*n2) / 2
+	variance := float64(n1*n2) / 12 * ((n + 1) - ties/(n*(n-1)))
+	if variance <= 0 {
+		return s
+	}
+	z := math.Max(math.Abs(s.U-mean)-0.5, 0)
+	s.PValue = math.Min(math.Erfc(z/math.Sqrt(2*variance)), 1)
+	return s
+}
+
+// samples returns a copy of the latencies kept by r.
+func samples(r Recorder) []float64 {
+	values := r.Values()
+	if len(values) == 0 {
+		return nil
+	}
+	return append([]float64{}, values...)
+}
@@ -0,0 +1,73 @@
+package bench_test
+
+import (
+	"io"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/thiagonache/bench"
+)
+
+func TestMannWhitneyU_ReportsSignificantDifference(t *testing.T) {
+	t.Parallel()
+	got := bench.MannWhitneyU([]float64{1, 2, 3, 4, 5}, []float64{6, 7, 8, 9, 10})
+	if got.U != 0 || got.N1 != 5 || got.N2 != 5 {
+		t.Errorf("want U 0 with 5+5 samples, got %+v", got)
+	}
+	// As computed by R's wilcox.test(exact = FALSE, correct = TRUE).
+	want := 0.01219
+	if math.Abs(want-got.PValue) > 0.00001 {
+		t.Errorf("want p-value %.5f, got %.5f", want, got.PValue)
+	}
+}
+
+func TestMannWhitneyU_CorrectsForTies(t *testing.T) {
+	t.Parallel()
+	got := bench.MannWhitneyU([]float64{1, 2, 2, 3, 3, 3}, []float64{2, 3, 4, 4, 5})
+	if got.U != 5.5 {
+		t.Errorf("want U 5.5, got %v", got.U)
+	}
+	want := 0.08871
+	if math.Abs(want-got.PValue) > 0.00001 {
+		t.Errorf("want p-value %.5f, got %.5f", want, got.PValue)
+	}
+}
+
+func TestMannWhitneyU_IsNotSignificantForSameOrMissingSamples(t *testing.T) {
+	t.Parallel()
+	tcs := map[string][2][]float64{
+		"identical": {{3, 3, 3}, {3, 3, 3}},
+		"same":      {{1, 2, 3, 4}, {4, 3, 2, 1}},
+		"empty":     {{1, 2, 3}, nil},
+	}
+	for name, tc := range tcs {
+		got := bench.MannWhitneyU(tc[0], tc[1])
+		if got.PValue != 1 {
+			t.Errorf("%s: want p-value 1, got %v", name, got.PValue)
+		}
+	}
+}
+
+func TestRun_KeepsSamplesInStatsWhenAsked(t *testing.T) {
+	t.Parallel()
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+	tester, err := bench.NewTester(
+		bench.FromArgs([]string{"run", "-r", "5", "-samples", "-u", server.URL}),
+		bench.WithStdout(io.Discard),
+		bench.WithStderr(io.Discard),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = tester.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := tester.Stats().Samples
+	if len(got) != 5 {
+		t.Errorf("want 5 samples, got %v", got)
+	}
+}
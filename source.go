@@ -0,0 +1,181 @@
+package bench
+
+import (
+	"errors"
+	"sync"
+)
+
+// RequestSource supplies the endpoint each worker requests next. Next
+// returns false once the source has run out of requests.
+type RequestSource interface {
+	Next() (Endpoint, bool)
+}
+
+func WithRequestSource(src RequestSource) Option {
+	return func(t *Tester) error {
+		if src == nil {
+			return ErrValueCannotBeNil
+		}
+		t.source = src
+		return nil
+	}
+}
+
+func (t Tester) RequestSource() RequestSource {
+	return t.source
+}
+
+// setupSource picks where requests come from: a custom source, a replay, a
+// scenario or, by default, a one-endpoint scenario for the URL. Loaded
+// results need no source.
+func (t *Tester) setupSource() error {
+	if len(t.results) > 0 {
+		return nil
+	}
+	sources := 0
+	for _, set := range []bool{t.source != nil, len(t.replay) > 0, len(t.scenario.Endpoints) > 0} {
+		if set {
+			sources++
+		}
+	}
+	if sources > 1 {
+		return errors.New("only one of request source, replay or scenario can be used")
+	}
+	switch {
+	case t.source != nil:
+		return nil
+	case len(t.replay) > 0:
+		return t.setupReplay()
+	case len(t.scenario.Endpoints) == 0:
+		if t.URL == "" {
+			return ErrNoURL
+		}
+		err := validateURL(t.URL)
+		if err != nil {
+			return err
+		}
+		t.scenario.Endpoints = []Endpoint{
+			{
+				Method: t.method,
+				URL:    t.URL,
+				Body:   string(t.body),
+			},
+		}
+	}
+	return t.setupScenario()
+}
+
+// endpointStats collects the statistics of a single endpoint alongside the
+// aggregate ones kept by the Tester.
+type endpointStats struct {
+	mu           *sync.Mutex
+	stats        Stats
+	TimeRecorder Recorder
+}
+
+func (e *endpointStats) RecordRequest() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.stats.Requests++
+}
+
+func (e *endpointStats) RecordSuccess() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.stats.Successes++
+}
+
+func (e *endpointStats) RecordError(class string, err error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.stats.recordError(class, err)
+}
+
+func (e *endpointStats) RecordBytes(sent, received int64) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.stats.RequestBytes += sent
+	e.stats.ResponseBytes += received
+}
+
+func (e *endpointStats) RecordStatusCode(code int) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.stats.recordStatusCode(code)
+}
+
+// statsGroup records a request in the statistics of its endpoint and, when
+// running in stages, of its stage.
+type statsGroup []*endpointStats
+
+func (g statsGroup) record(r result) {
+	for _, s := range g {
+		s.RecordRequest()
+		if r.responded {
+			s.RecordStatusCode(r.statusCode)
+			s.TimeRecorder.RecordTime(milliseconds(r.elapsed()))
+			s.RecordBytes(r.sent, r.received)
+		}
+		if r.err != nil {
+			s.RecordError(r.class, r.err)
+			continue
+		}
+		s.RecordSuccess()
+	}
+}
+
+// endpointStatsFor returns the statistics kept under the endpoint's name,
+// creating them the first time the name is seen. Endpoints sharing a name,
+// such as replayed requests to the same path, share their statistics.
+func (t *Tester) endpointStatsFor(e Endpoint) (*endpointStats, error) {
+	name := e.Name
+	if name == "" {
+		name = e.Method + " " + e.URL
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	stats, ok := t.endpoints[name]
+	if ok {
+		return stats, nil
+	}
+	recorder, err := t.newRecorder()
+	if err != nil {
+		return nil, err
+	}
+	stats = &endpointStats{
+		mu: &sync.Mutex{},
+		stats: Stats{
+			Endpoint: name,
+			URL:      e.URL,
+		},
+		TimeRecorder: recorder,
+	}
+	t.endpoints[name] = stats
+	t.endpointNames = append(t.endpointNames, name)
+	return stats, nil
+}
+
+func (t *Tester) endpointMetrics() []Stats {
+	t.mu.Lock()
+	endpoints := make([]*endpointStats, len(t.endpointNames))
+	for i, name := range t.endpointNames {
+		endpoints[i] = t.endpoints[name]
+	}
+	t.mu.Unlock()
+	if len(endpoints) < 2 {
+		return nil
+	}
+	stats := make([]Stats, len(endpoints))
+	for i, e := range endpoints {
+		e.mu.Lock()
+		stats[i] = e.stats
+		e.mu.Unlock()
+		if e.TimeRecorder.Count() > 0 {
+			t.setRecorderMetrics(&stats[i], e.TimeRecorder)
+		}
+		if t.statsSamples {
+			stats[i].Samples = samples(e.TimeRecorder)
+		}
+	}
+	return stats
+}
@@ -0,0 +1,207 @@
+package bench
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// stageInterval is how often the number of workers is adjusted during a
+// staged run.
+const stageInterval = 50 * time.Millisecond
+
+// Stage moves the number of workers linearly from the target of the previous
+// stage, or none for the first one, to Target over Duration. A stage with the
+// same target as the previous one holds the load steady.
+type Stage struct {
+	Duration time.Duration
+	Target   int
+}
+
+// ParseStages parses comma-separated stages given as duration:workers. For
+// example, "1m:200,5m:200,30s:0" ramps up to 200 workers over a minute,
+// holds them for five minutes and ramps down over 30 seconds.
+func ParseStages(s string) ([]Stage, error) {
+	stages := []Stage{}
+	for _, field := range strings.Split(s, ",") {
+		parts := strings.SplitN(strings.TrimSpace(field), ":", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("invalid stage %q, want 'duration:workers'", field)
+		}
+		d, err := time.ParseDuration(parts[0])
+		if err != nil {
+			return nil, fmt.Errorf("invalid stage %q: %w", field, err)
+		}
+		target, err := strconv.Atoi(parts[1])
+		if err != nil {
+			return nil, fmt.Errorf("invalid stage %q: %w", field, err)
+		}
+		stages = append(stages, Stage{Duration: d, Target: target})
+	}
+	return stages, nil
+}
+
+// WithStages runs the benchmark through stages instead of a fixed number of
+// workers, for as long as the stages last. Stats are reported for each stage
+// as well as for the whole run.
+func WithStages(stages ...Stage) Option {
+	return func(t *Tester) error {
+		if len(stages) == 0 {
+			return errors.New("no stages")
+		}
+		for _, s := range stages {
+			if s.Duration < 0 {
+				return fmt.Errorf("%v is invalid stage duration", s.Duration)
+			}
+			if s.Target < 0 {
+				return fmt.Errorf("%d is invalid stage target", s.Target)
+			}
+		}
+		t.stages = stages
+		return nil
+	}
+}
+
+func (t Tester) Stages() []Stage {
+	return t.stages
+}
+
+// WorkersAt returns how many workers the stages call for once elapsed has
+// passed. At least one worker runs until the last stage ends.
+func WorkersAt(stages []Stage, elapsed time.Duration) int {
+	from := 0
+	for _, s := range stages {
+		if elapsed < s.Duration {
+			progress := float64(elapsed) / float64(s.Duration)
+			workers := int(math.Round(float64(from) + float64(s.Target-from)*progress))
+			if workers < 1 {
+				return 1
+			}
+			return workers
+		}
+		elapsed -= s.Duration
+		from = s.Target
+	}
+	if from < 1 {
+		return 1
+	}
+	return from
+}
+
+// stageAt returns the index of the stage running once elapsed has passed.
+func stageAt(stages []Stage, elapsed time.Duration) int {
+	for i, s := range stages {
+		if elapsed < s.Duration {
+			return i
+		}
+		elapsed -= s.Duration
+	}
+	return len(stages) - 1
+}
+
+// setupStages makes the stages set the run duration and creates their
+// statistics.
+func (t *Tester) setupStages() error {
+	if len(t.stages) == 0 {
+		return nil
+	}
+	if t.rate > 0 {
+		return errors.New("stages and rate cannot be used together")
+	}
+	if t.duration > 0 {
+		return errors.New("stages and duration cannot be used together")
+	}
+	var total time.Duration
+	for _, s := range t.stages {
+		total += s.Duration
+	}
+	if total == 0 {
+		return errors.New("stages have no duration")
+	}
+	t.duration = total
+	t.stageStats = make([]*endpointStats, len(t.stages))
+	for i := range t.stages {
+		recorder, err := t.newRecorder()
+		if err != nil {
+			return err
+		}
+		t.stageStats[i] = &endpointStats{
+			mu:           &sync.Mutex{},
+			stats:        Stats{Stage: i + 1},
+			TimeRecorder: recorder,
+		}
+	}
+	return nil
+}
+
+// stageStatsFor returns the statistics of the stage running now, if any.
+func (t *Tester) stageStatsFor(now time.Time) *endpointStats {
+	if len(t.stageStats) == 0 {
+		return nil
+	}
+	return t.stageStats[stageAt(t.stages, now.Sub(t.startAt))]
+}
+
+// controlWorkers starts and stops workers as the stages go, until they end
+// or the run is aborted. Stopped workers finish their request in flight.
+func (t *Tester) controlWorkers() {
+	defer t.wg.Done()
+	stops := []chan struct{}{}
+	ticker := time.NewTicker(stageInterval)
+	defer ticker.Stop()
+	for {
+		elapsed := time.Since(t.startAt)
+		if elapsed >= t.duration {
+			return
+		}
+		want := WorkersAt(t.stages, elapsed)
+		for len(stops) < want {
+			stop := make(chan struct{})
+			stops = append(stops, stop)
+			t.wg.Add(1)
+			go func() {
+				t.work(stop)
+				t.wg.Done()
+			}()
+		}
+		for len(stops) > want {
+			close(stops[len(stops)-1])
+			stops = stops[:len(stops)-1]
+		}
+		select {
+		case <-ticker.C:
+		case <-t.aborted:
+			return
+		}
+	}
+}
+
+func (t *Tester) stageMetrics() []Stats {
+	if len(t.stageStats) == 0 {
+		return nil
+	}
+	stats := make([]Stats, len(t.stageStats))
+	var start time.Duration
+	for i, s := range t.stageStats {
+		s.mu.Lock()
+		stats[i] = s.stats
+		s.mu.Unlock()
+		if s.TimeRecorder.Count() > 0 {
+			t.setRecorderMetrics(&stats[i], s.TimeRecorder)
+		}
+		stats[i].ConfiguredDuration = t.stages[i].Duration
+		ran := t.EndAt - start
+		if ran > t.stages[i].Duration {
+			ran = t.stages[i].Duration
+		}
+		if ran > 0 {
+			stats[i].Duration = ran
+		}
+		start += t.stages[i].Duration
+	}
+	return stats
+}
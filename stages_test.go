@@ -0,0 +1,203 @@
+package bench_test
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/thiagonache/bench"
+)
+
+func TestParseStages_ParsesDurationsAndTargets(t *testing.T) {
+	t.Parallel()
+	want := []bench.Stage{
+		{Duration: time.Minute, Target: 200},
+		{Duration: 5 * time.Minute, Target: 200},
+		{Duration: 30 * time.Second, Target: 0},
+	}
+	got, err := bench.ParseStages("1m:200, 5m:200,30s:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !cmp.Equal(want, got) {
+		t.Error(cmp.Diff(want, got))
+	}
+}
+
+func TestParseStages_ErrorsIfStageIsInvalid(t *testing.T) {
+	t.Parallel()
+	for _, s := range []string{"1m", "bogus:10", "1m:many", "1m:10,"} {
+		_, err := bench.ParseStages(s)
+		if err == nil {
+			t.Errorf("want error for %q", s)
+		}
+	}
+}
+
+func TestWorkersAt_RampsLinearlyBetweenStageTargets(t *testing.T) {
+	t.Parallel()
+	stages := []bench.Stage{
+		{Duration: 10 * time.Second, Target: 100},
+		{Duration: 10 * time.Second, Target: 100},
+		{Duration: 10 * time.Second, Target: 0},
+	}
+	tcs := []struct {
+		elapsed time.Duration
+		want    int
+	}{
+		{elapsed: 0, want: 1},
+		{elapsed: 5 * time.Second, want: 50},
+		{elapsed: 10 * time.Second, want: 100},
+		{elapsed: 15 * time.Second, want: 100},
+		{elapsed: 22 * time.Second, want: 80},
+		{elapsed: 30 * time.Second, want: 1},
+	}
+	for _, tc := range tcs {
+		got := bench.WorkersAt(stages, tc.elapsed)
+		if tc.want != got {
+			t.Errorf("at %v want %d workers, got %d", tc.elapsed, tc.want, got)
+		}
+	}
+}
+
+func TestNewTester_StagesSetDuration(t *testing.T) {
+	t.Parallel()
+	tester, err := bench.NewTester(
+		bench.WithURL("http://fake.url"),
+		bench.WithStages(
+			bench.Stage{Duration: time.Minute, Target: 10},
+			bench.Stage{Duration: 30 * time.Second, Target: 0},
+		),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := 90 * time.Second
+	got := tester.Duration()
+	if want != got {
+		t.Errorf("want duration %v, got %v", want, got)
+	}
+}
+
+func TestNewTester_ErrorsIfStagesAreInvalid(t *testing.T) {
+	t.Parallel()
+	tcs := []struct {
+		name string
+		opts []bench.Option
+	}{
+		{
+			name: "no stages",
+			opts: []bench.Option{bench.WithStages()},
+		},
+		{
+			name: "negative target",
+			opts: []bench.Option{bench.WithStages(bench.Stage{Duration: time.Second, Target: -1})},
+		},
+		{
+			name: "no duration",
+			opts: []bench.Option{bench.WithStages(bench.Stage{Target: 10})},
+		},
+		{
+			name: "with rate",
+			opts: []bench.Option{bench.WithStages(bench.Stage{Duration: time.Second, Target: 10}), bench.WithRate(10)},
+		},
+		{
+			name: "with duration",
+			opts: []bench.Option{bench.WithStages(bench.Stage{Duration: time.Second, Target: 10}), bench.WithDuration(time.Second)},
+		},
+	}
+	for _, tc := range tcs {
+		opts := append([]bench.Option{bench.WithURL("http://fake.url")}, tc.opts...)
+		_, err := bench.NewTester(opts...)
+		if err == nil {
+			t.Errorf("%s: want error", tc.name)
+		}
+	}
+}
+
+func TestFromArgs_SetsStages(t *testing.T) {
+	t.Parallel()
+	tester, err := bench.NewTester(
+		bench.FromArgs([]string{"run", "-u", "http://fake.url", "-stages", "2s:5,1s:0"}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []bench.Stage{
+		{Duration: 2 * time.Second, Target: 5},
+		{Duration: time.Second, Target: 0},
+	}
+	got := tester.Stages()
+	if !cmp.Equal(want, got) {
+		t.Error(cmp.Diff(want, got))
+	}
+}
+
+func TestRun_ResizesWorkersAndReportsStatsPerStage(t *testing.T) {
+	t.Parallel()
+	mu := &sync.Mutex{}
+	inFlight, maxInFlight := 0, 0
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		inFlight++
+		if inFlight > maxInFlight {
+			maxInFlight = inFlight
+		}
+		mu.Unlock()
+		time.Sleep(5 * time.Millisecond)
+		mu.Lock()
+		inFlight--
+		mu.Unlock()
+	}))
+	defer server.Close()
+	tester, err := bench.NewTester(
+		bench.WithURL(server.URL),
+		bench.WithStages(
+			bench.Stage{Duration: 200 * time.Millisecond, Target: 4},
+			bench.Stage{Duration: 200 * time.Millisecond, Target: 4},
+		),
+		bench.WithStdout(io.Discard),
+		bench.WithStderr(io.Discard),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = tester.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	mu.Lock()
+	got := maxInFlight
+	mu.Unlock()
+	if got < 2 || got > 4 {
+		t.Errorf("want between 2 and 4 requests in flight at most, got %d", got)
+	}
+	stats := tester.Stats()
+	if len(stats.Stages) != 2 {
+		t.Fatalf("want stats for 2 stages, got %d", len(stats.Stages))
+	}
+	requests := 0
+	for i, s := range stats.Stages {
+		if s.Stage != i+1 {
+			t.Errorf("want stage %d, got %d", i+1, s.Stage)
+		}
+		if s.Requests == 0 || s.Requests != s.Successes {
+			t.Errorf("stage %d: want only successful requests, got %d of %d", s.Stage, s.Successes, s.Requests)
+		}
+		if s.ConfiguredDuration != 200*time.Millisecond {
+			t.Errorf("stage %d: want configured duration 200ms, got %v", s.Stage, s.ConfiguredDuration)
+		}
+		requests += s.Requests
+	}
+	if requests != stats.Requests {
+		t.Errorf("want stage requests to add up to %d, got %d", stats.Requests, requests)
+	}
+	if stats.Stages[1].Requests <= stats.Stages[0].Requests {
+		t.Errorf("want more requests while holding 4 workers than while ramping up, got %d and %d",
+			stats.Stages[1].Requests, stats.Stages[0].Requests)
+	}
+}
@@ -0,0 +1,770 @@
+package bench
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// StatsFileVersion is the version of the stats file format written by
+// WriteStatsFileJSON and WriteStatsFileCSV.
+const StatsFileVersion = 1
+
+const (
+	statsFileCSVMagic = "# simplebench stats v"
+
+	EntryRun      = "run"
+	EntryEndpoint = "endpoint"
+	EntryStage    = "stage"
+	EntryWarmup   = "warmup"
+)
+
+var (
+	ErrEmptyStatsFile = errors.New("empty stats file")
+
+	statsCSVColumns = []string{
+		"entry", "url", "endpoint", "stage", "requests", "successes", "failures",
+		"mean", "min", "max", "stddev", "p50", "p90", "p99",
+		"corrected_p50", "corrected_p90", "corrected_p99", "late", "dropped",
+		"request_bytes", "response_bytes", "duration", "configured_duration",
+	}
+)
+
+// StatsFile is the content of a stats file: the statistics of one or more
+// runs along with when, where and how they ran.
+type StatsFile struct {
+	Version  int
+	Metadata RunMetadata
+	Config   RunConfig
+	Stats    []Stats
+}
+
+// RunMetadata describes where and when a benchmark ran.
+type RunMetadata struct {
+	StartedAt time.Time
+	Hostname  string
+	GoVersion string
+	UserAgent string
+}
+
+// RunConfig is the configuration a benchmark ran with.
+type RunConfig struct {
+	URL              string
+	Method           string
+	Endpoints        []string
+	Concurrency      int
+	Requests         int
+	Duration         time.Duration
+	Rate             float64
+	ExpectedInterval time.Duration
+	Percentiles      []float64
+	Warmup           time.Duration
+	WarmupRequests   int
+	Stages           []Stage
+	MaxErrors        int
+	MaxErrorRate     float64
+	Seed             int64
+}
+
+// WithStatsFormat sets the format the stats file is written in, FormatJSON
+// by default. The file is named after it, stats.json or stats.csv.
+func WithStatsFormat(format string) Option {
+	return func(t *Tester) error {
+		if format != FormatJSON && format != FormatCSV {
+			return fmt.Errorf("%q is invalid stats file format, want %s or %s", format, FormatJSON, FormatCSV)
+		}
+		t.statsFormat = format
+		return nil
+	}
+}
+
+// WithStatsSamples keeps the latency of every request in the stats, and so in
+// the stats file, for comparisons to test the significance of changes with.
+// HDR recorders keep a summary of at most a few thousand values instead.
+func WithStatsSamples(keep bool) Option {
+	return func(t *Tester) error {
+		t.statsSamples = keep
+		return nil
+	}
+}
+
+// StatsFile returns the stats of the run with its metadata and configuration.
+func (t Tester) StatsFile() StatsFile {
+	hostname, _ := os.Hostname()
+	return StatsFile{
+		Version: StatsFileVersion,
+		Metadata: RunMetadata{
+			StartedAt: t.startAt,
+			Hostname:  hostname,
+			GoVersion: runtime.Version(),
+			UserAgent: t.userAgent,
+		},
+		Config: RunConfig{
+			URL:              t.URL,
+			Method:           t.method,
+			Endpoints:        t.endpointNames,
+			Concurrency:      t.Concurrency,
+			Requests:         t.requests,
+			Duration:         t.duration,
+			Rate:             t.rate,
+			ExpectedInterval: t.expectedInterval,
+			Percentiles:      t.percentiles,
+			Warmup:           t.warmup,
+			WarmupRequests:   t.warmupRequests,
+			Stages:           t.stages,
+			MaxErrors:        t.maxErrors,
+			MaxErrorRate:     t.maxErrorRate,
+			Seed:             t.seed,
+		},
+		Stats: []Stats{t.stats},
+	}
+}
+
+func (t *Tester) exportStats() error {
+	format := t.statsFormat
+	if format == "" {
+		format = FormatJSON
+	}
+	file, err := os.Create(fmt.Sprintf("%s/stats.%s", t.OutputPath, format))
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	if format == FormatCSV {
+		return WriteStatsFileCSV(file, t.StatsFile())
+	}
+	return WriteStatsFileJSON(file, t.StatsFile())
+}
+
+// WriteStatsFile writes stats as a JSON stats file without metadata.
+func WriteStatsFile(w io.Writer, stats Stats) error {
+	return WriteStatsFileJSON(w, StatsFile{
+		Version: StatsFileVersion,
+		Stats:   []Stats{stats},
+	})
+}
+
+// WriteStatsFileJSON writes f as indented JSON. Durations are in
+// nanoseconds and latencies in milliseconds.
+func WriteStatsFileJSON(w io.Writer, f StatsFile) error {
+	f.Version = StatsFileVersion
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(f)
+}
+
+// WriteStatsFileCSV writes f as CSV preceded by comment lines holding the
+// format version, the metadata and the configuration as JSON. Each run takes
+// a row, followed by a row for each of its endpoints, stages and warm-up.
+// Percentiles, errors, status codes, assertion failures and phases get a
+// column each, such as P75, error:timeout or phase:dns:mean, and samples are
+// space-separated in a single column. Time series are left out, as they have
+// their own export.
+func WriteStatsFileCSV(w io.Writer, f StatsFile) error {
+	metadata, err := json.Marshal(f.Metadata)
+	if err != nil {
+		return err
+	}
+	config, err := json.Marshal(f.Config)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "%s%d\n# metadata: %s\n# config: %s\n", statsFileCSVMagic, StatsFileVersion, metadata, config)
+	if err != nil {
+		return err
+	}
+	rows := []map[string]string{}
+	columns := append([]string{}, statsCSVColumns...)
+	seen := map[string]bool{}
+	add := func(entry string, s Stats) {
+		row := statsCSVRow(entry, s)
+		for _, c := range statsCSVExtraColumns(s) {
+			if !seen[c.name] {
+				seen[c.name] = true
+				columns = append(columns, c.name)
+			}
+			row[c.name] = c.value
+		}
+		rows = append(rows, row)
+	}
+	for _, s := range f.Stats {
+		add(EntryRun, s)
+		for _, e := range s.Endpoints {
+			add(EntryEndpoint, e)
+		}
+		for _, st := range s.Stages {
+			add(EntryStage, st)
+		}
+		if s.Warmup != nil {
+			add(EntryWarmup, *s.Warmup)
+		}
+	}
+	cw := csv.NewWriter(w)
+	err = cw.Write(columns)
+	if err != nil {
+		return err
+	}
+	for _, row := range rows {
+		record := make([]string, len(columns))
+		for i, c := range columns {
+			record[i] = row[c]
+		}
+		err = cw.Write(record)
+		if err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+func statsCSVRow(entry string, s Stats) map[string]string {
+	float := func(v float64) string {
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	}
+	return map[string]string{
+		"entry":               entry,
+		"url":                 s.URL,
+		"endpoint":            s.Endpoint,
+		"stage":               strconv.Itoa(s.Stage),
+		"requests":            strconv.Itoa(s.Requests),
+		"successes":           strconv.Itoa(s.Successes),
+		"failures":            strconv.Itoa(s.Failures),
+		"mean":                float(s.Mean),
+		"min":                 float(s.Min),
+		"max":                 float(s.Max),
+		"stddev":              float(s.StdDev),
+		"p50":                 float(s.P50),
+		"p90":                 float(s.P90),
+		"p99":                 float(s.P99),
+		"corrected_p50":       float(s.CorrectedP50),
+		"corrected_p90":       float(s.CorrectedP90),
+		"corrected_p99":       float(s.CorrectedP99),
+		"late":                strconv.Itoa(s.Late),
+		"dropped":             strconv.Itoa(s.Dropped),
+		"request_bytes":       strconv.FormatInt(s.RequestBytes, 10),
+		"response_bytes":      strconv.FormatInt(s.ResponseBytes, 10),
+		"duration":            s.Duration.String(),
+		"configured_duration": s.ConfiguredDuration.String(),
+	}
+}
+
+type statsColumn struct {
+	name  string
+	value string
+}
+
+// statsCSVExtraColumns returns the columns of the percentiles, counts,
+// phases and samples of s, named as the fields of the legacy format.
+func statsCSVExtraColumns(s Stats) []statsColumn {
+	float := func(v float64) string {
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	}
+	columns := []statsColumn{}
+	for _, p := range s.Percentiles {
+		columns = append(columns, statsColumn{p.Label(), float(p.Value)})
+	}
+	for _, p := range s.CorrectedPercentiles {
+		columns = append(columns, statsColumn{"corrected:" + p.Label(), float(p.Value)})
+	}
+	for _, class := range sortedKeys(s.Errors) {
+		columns = append(columns, statsColumn{"error:" + class, strconv.Itoa(s.Errors[class])})
+	}
+	codes := make([]int, 0, len(s.StatusCodes))
+	for code := range s.StatusCodes {
+		codes = append(codes, code)
+	}
+	sort.Ints(codes)
+	for _, code := range codes {
+		columns = append(columns, statsColumn{fmt.Sprintf("status:%d", code), strconv.Itoa(s.StatusCodes[code])})
+	}
+	for _, reason := range sortedKeys(s.AssertionFailures) {
+		columns = append(columns, statsColumn{"assertion:" + reason, strconv.Itoa(s.AssertionFailures[reason])})
+	}
+	for _, phase := range s.Phases {
+		prefix := "phase:" + phase.Phase + ":"
+		columns = append(columns, statsColumn{prefix + "count", strconv.Itoa(phase.Count)})
+		columns = append(columns, statsColumn{prefix + "mean", float(phase.Mean)})
+		for _, p := range phase.Percentiles {
+			columns = append(columns, statsColumn{prefix + p.Label(), float(p.Value)})
+		}
+	}
+	if len(s.Samples) > 0 {
+		values := make([]string, len(s.Samples))
+		for i, v := range s.Samples {
+			values[i] = float(v)
+		}
+		columns = append(columns, statsColumn{"samples", strings.Join(values, " ")})
+	}
+	return columns
+}
+
+// parseSamples parses the space-separated latencies of the samples column.
+func parseSamples(data string) ([]float64, error) {
+	fields := strings.Fields(data)
+	if len(fields) == 0 {
+		return nil, nil
+	}
+	samples := make([]float64, len(fields))
+	for i, f := range fields {
+		v, err := strconv.ParseFloat(f, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid sample %q", f)
+		}
+		samples[i] = v
+	}
+	return samples, nil
+}
+
+func sortedKeys(m map[string]int) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// ReadStatsFile reads the stats of every run in a stats file.
+func ReadStatsFile(r io.Reader) ([]Stats, error) {
+	f, err := DecodeStatsFile(r)
+	if err != nil {
+		return nil, err
+	}
+	return f.Stats, nil
+}
+
+// ReadStatsFilePath reads the stats file at path.
+func ReadStatsFilePath(path string) (StatsFile, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return StatsFile{}, err
+	}
+	defer file.Close()
+	f, err := DecodeStatsFile(file)
+	if err != nil {
+		return StatsFile{}, fmt.Errorf("%s: %w", path, err)
+	}
+	return f, nil
+}
+
+// DecodeStatsFile reads a stats file in any of the formats written by this
+// package, telling them apart by their content: JSON, CSV, or the
+// comma-separated lines of the legacy format, which have no metadata.
+func DecodeStatsFile(r io.Reader) (StatsFile, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return StatsFile{}, err
+	}
+	trimmed := bytes.TrimSpace(data)
+	switch {
+	case len(trimmed) == 0:
+		return StatsFile{}, ErrEmptyStatsFile
+	case trimmed[0] == '{':
+		return decodeStatsFileJSON(data)
+	case bytes.HasPrefix(trimmed, []byte(statsFileCSVMagic)):
+		return decodeStatsFileCSV(data)
+	}
+	stats, err := readLegacyStatsFile(data)
+	if err != nil {
+		return StatsFile{}, err
+	}
+	return StatsFile{Stats: stats}, nil
+}
+
+func decodeStatsFileJSON(data []byte) (StatsFile, error) {
+	f := StatsFile{}
+	err := json.Unmarshal(data, &f)
+	if err != nil {
+		var offset int64 = -1
+		syntaxErr := &json.SyntaxError{}
+		typeErr := &json.UnmarshalTypeError{}
+		switch {
+		case errors.As(err, &syntaxErr):
+			offset = syntaxErr.Offset
+		case errors.As(err, &typeErr):
+			offset = typeErr.Offset
+		}
+		if offset >= 0 {
+			return StatsFile{}, fmt.Errorf("line %d: %w", lineAt(data, offset), err)
+		}
+		return StatsFile{}, err
+	}
+	if f.Version < 1 || f.Version > StatsFileVersion {
+		return StatsFile{}, fmt.Errorf("unsupported stats file version %d", f.Version)
+	}
+	return f, nil
+}
+
+func lineAt(data []byte, offset int64) int {
+	if offset > int64(len(data)) {
+		offset = int64(len(data))
+	}
+	return bytes.Count(data[:offset], []byte("\n")) + 1
+}
+
+func decodeStatsFileCSV(data []byte) (StatsFile, error) {
+	f := StatsFile{}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(nil, len(data)+1)
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := scanner.Text()
+		if !strings.HasPrefix(text, "#") {
+			break
+		}
+		var err error
+		switch {
+		case strings.HasPrefix(text, statsFileCSVMagic):
+			f.Version, err = strconv.Atoi(strings.TrimPrefix(text, statsFileCSVMagic))
+			if err == nil && (f.Version < 1 || f.Version > StatsFileVersion) {
+				err = fmt.Errorf("unsupported stats file version %d", f.Version)
+			}
+		case strings.HasPrefix(text, "# metadata: "):
+			err = json.Unmarshal([]byte(strings.TrimPrefix(text, "# metadata: ")), &f.Metadata)
+		case strings.HasPrefix(text, "# config: "):
+			err = json.Unmarshal([]byte(strings.TrimPrefix(text, "# config: ")), &f.Config)
+		}
+		if err != nil {
+			return StatsFile{}, fmt.Errorf("line %d: %w", line, err)
+		}
+	}
+	cr := csv.NewReader(bytes.NewReader(data))
+	cr.Comment = '#'
+	header, err := cr.Read()
+	if err == io.EOF {
+		return StatsFile{}, fmt.Errorf("line %d: no header", line)
+	}
+	if err != nil {
+		return StatsFile{}, err
+	}
+	for _, column := range statsCSVColumns {
+		if !containsString(header, column) {
+			lineNum, _ := cr.FieldPos(0)
+			return StatsFile{}, fmt.Errorf("line %d: header has no %s column", lineNum, column)
+		}
+	}
+	for {
+		record, err := cr.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return StatsFile{}, err
+		}
+		lineNum, _ := cr.FieldPos(0)
+		entry, s, err := parseStatsCSVRow(header, record)
+		if err != nil {
+			return StatsFile{}, fmt.Errorf("line %d: %w", lineNum, err)
+		}
+		if entry == EntryRun {
+			f.Stats = append(f.Stats, s)
+			continue
+		}
+		if len(f.Stats) == 0 {
+			return StatsFile{}, fmt.Errorf("line %d: %s entry before any run", lineNum, entry)
+		}
+		run := &f.Stats[len(f.Stats)-1]
+		switch entry {
+		case EntryEndpoint:
+			run.Endpoints = append(run.Endpoints, s)
+		case EntryStage:
+			run.Stages = append(run.Stages, s)
+		case EntryWarmup:
+			warmup := s
+			run.Warmup = &warmup
+		default:
+			return StatsFile{}, fmt.Errorf("line %d: invalid entry %q", lineNum, entry)
+		}
+	}
+	return f, nil
+}
+
+func containsString(values []string, s string) bool {
+	for _, v := range values {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+func parseStatsCSVRow(header, record []string) (string, Stats, error) {
+	s := Stats{}
+	entry := ""
+	for i, column := range header {
+		value := record[i]
+		var err error
+		switch column {
+		case "entry":
+			entry = value
+		case "url":
+			s.URL = value
+		case "endpoint":
+			s.Endpoint = value
+		case "stage":
+			s.Stage, err = strconv.Atoi(value)
+		case "requests":
+			s.Requests, err = strconv.Atoi(value)
+		case "successes":
+			s.Successes, err = strconv.Atoi(value)
+		case "failures":
+			s.Failures, err = strconv.Atoi(value)
+		case "mean":
+			s.Mean, err = strconv.ParseFloat(value, 64)
+		case "min":
+			s.Min, err = strconv.ParseFloat(value, 64)
+		case "max":
+			s.Max, err = strconv.ParseFloat(value, 64)
+		case "stddev":
+			s.StdDev, err = strconv.ParseFloat(value, 64)
+		case "p50":
+			s.P50, err = strconv.ParseFloat(value, 64)
+		case "p90":
+			s.P90, err = strconv.ParseFloat(value, 64)
+		case "p99":
+			s.P99, err = strconv.ParseFloat(value, 64)
+		case "corrected_p50":
+			s.CorrectedP50, err = strconv.ParseFloat(value, 64)
+		case "corrected_p90":
+			s.CorrectedP90, err = strconv.ParseFloat(value, 64)
+		case "corrected_p99":
+			s.CorrectedP99, err = strconv.ParseFloat(value, 64)
+		case "late":
+			s.Late, err = strconv.Atoi(value)
+		case "dropped":
+			s.Dropped, err = strconv.Atoi(value)
+		case "request_bytes":
+			s.RequestBytes, err = strconv.ParseInt(value, 10, 64)
+		case "response_bytes":
+			s.ResponseBytes, err = strconv.ParseInt(value, 10, 64)
+		case "duration":
+			s.Duration, err = time.ParseDuration(value)
+		case "configured_duration":
+			s.ConfiguredDuration, err = time.ParseDuration(value)
+		case "samples":
+			s.Samples, err = parseSamples(value)
+		default:
+			if value != "" {
+				err = parseStatsField(&s, column+"="+value)
+			}
+		}
+		if err != nil {
+			return "", Stats{}, fmt.Errorf("column %s: %w", column, err)
+		}
+	}
+	return entry, s, nil
+}
+
+// readLegacyStatsFile reads the comma-separated lines written before stats
+// files were versioned: URL, requests, successes, failures, P50, P90 and P99,
+// optionally followed by mean, min, max and standard deviation and by the
+// fields parsed by parseStatsField.
+func readLegacyStatsFile(data []byte) ([]Stats, error) {
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	stats := []Stats{}
+	line := 0
+	for scanner.Scan() {
+		line++
+		if strings.TrimSpace(scanner.Text()) == "" {
+			continue
+		}
+		s, err := parseLegacyStats(scanner.Text())
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		stats = append(stats, s)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return stats, nil
+}
+
+// parseLegacyStats parses a legacy line from its end, as the URL may contain
+// commas. The fields parsed by parseStatsField, which all hold "=", come
+// last. Lines with them have mean, min, max and standard deviation too,
+// since they were always written together.
+func parseLegacyStats(text string) (Stats, error) {
+	fields := strings.Split(text, ",")
+	end := len(fields)
+	for end > 0 && strings.Contains(fields[end-1], "=") {
+		end--
+	}
+	fixed := 6
+	if end < len(fields) {
+		fixed = 10
+	}
+	if end-fixed < 1 {
+		return Stats{}, fmt.Errorf("want at least %d fields, got %d", fixed+1, len(fields))
+	}
+	pos := append([]string{strings.Join(fields[:end-fixed], ",")}, fields[end-fixed:]...)
+	s := Stats{URL: pos[0]}
+	var err error
+	counts := []*int{&s.Requests, &s.Successes, &s.Failures}
+	for i, data := range pos[1:4] {
+		*counts[i], err = strconv.Atoi(data)
+		if err != nil {
+			return Stats{}, fmt.Errorf("field %d: %w", i+2, err)
+		}
+	}
+	values := []*float64{&s.P50, &s.P90, &s.P99, &s.Mean, &s.Min, &s.Max, &s.StdDev}
+	if len(pos) < 11 {
+		values = values[:3]
+	}
+	for i, v := range values {
+		*v, err = strconv.ParseFloat(pos[4+i], 64)
+		if err != nil {
+			return Stats{}, fmt.Errorf("field %d: %w", i+5, err)
+		}
+	}
+	if len(pos) >= 11 {
+		for i, data := range pos[11:] {
+			err := parseStatsField(&s, data)
+			if err != nil {
+				return Stats{}, fmt.Errorf("field %d: %w", i+12, err)
+			}
+		}
+	}
+	return s, nil
+}
+
+// parseStatsField parses the optional fields following the summary
+// statistics: percentiles as P75=value, corrected percentiles as
+// corrected:P75=value, errors as error:class=count, status codes as
+// status:code=count, assertion failures as assertion:reason=count, body
+// bytes as bytes:request=n and bytes:response=n, and phases as
+// phase:name:count=n, phase:name:mean=value and phase:name:P75=value.
+func parseStatsField(s *Stats, data string) error {
+	switch {
+	case strings.HasPrefix(data, "corrected:"):
+		p, err := parsePercentile(strings.TrimPrefix(data, "corrected:"))
+		if err != nil {
+			return err
+		}
+		s.CorrectedPercentiles = append(s.CorrectedPercentiles, p)
+	case strings.HasPrefix(data, "assertion:"):
+		fields := strings.SplitN(strings.TrimPrefix(data, "assertion:"), "=", 2)
+		if len(fields) != 2 {
+			return fmt.Errorf("invalid assertion failure count %q", data)
+		}
+		n, err := strconv.Atoi(fields[1])
+		if err != nil {
+			return err
+		}
+		if s.AssertionFailures == nil {
+			s.AssertionFailures = map[string]int{}
+		}
+		s.AssertionFailures[fields[0]] = n
+	case strings.HasPrefix(data, "bytes:"):
+		fields := strings.SplitN(strings.TrimPrefix(data, "bytes:"), "=", 2)
+		if len(fields) != 2 {
+			return fmt.Errorf("invalid bytes %q", data)
+		}
+		n, err := strconv.ParseInt(fields[1], 10, 64)
+		if err != nil {
+			return err
+		}
+		switch fields[0] {
+		case "request":
+			s.RequestBytes = n
+		case "response":
+			s.ResponseBytes = n
+		default:
+			return fmt.Errorf("invalid bytes %q", data)
+		}
+	case strings.HasPrefix(data, "phase:"):
+		fields := strings.SplitN(strings.TrimPrefix(data, "phase:"), ":", 2)
+		if len(fields) != 2 {
+			return fmt.Errorf("invalid phase %q", data)
+		}
+		if len(s.Phases) == 0 || s.Phases[len(s.Phases)-1].Phase != fields[0] {
+			s.Phases = append(s.Phases, PhaseStats{Phase: fields[0]})
+		}
+		phase := &s.Phases[len(s.Phases)-1]
+		switch {
+		case strings.HasPrefix(fields[1], "count="):
+			n, err := strconv.Atoi(strings.TrimPrefix(fields[1], "count="))
+			if err != nil {
+				return err
+			}
+			phase.Count = n
+		case strings.HasPrefix(fields[1], "mean="):
+			v, err := strconv.ParseFloat(strings.TrimPrefix(fields[1], "mean="), 64)
+			if err != nil {
+				return err
+			}
+			phase.Mean = v
+		default:
+			p, err := parsePercentile(fields[1])
+			if err != nil {
+				return err
+			}
+			phase.Percentiles = append(phase.Percentiles, p)
+		}
+	case strings.HasPrefix(data, "error:"):
+		fields := strings.SplitN(strings.TrimPrefix(data, "error:"), "=", 2)
+		if len(fields) != 2 {
+			return fmt.Errorf("invalid error count %q", data)
+		}
+		n, err := strconv.Atoi(fields[1])
+		if err != nil {
+			return err
+		}
+		if s.Errors == nil {
+			s.Errors = map[string]int{}
+		}
+		s.Errors[fields[0]] = n
+	case strings.HasPrefix(data, "status:"):
+		fields := strings.SplitN(strings.TrimPrefix(data, "status:"), "=", 2)
+		if len(fields) != 2 {
+			return fmt.Errorf("invalid status code count %q", data)
+		}
+		code, err := strconv.Atoi(fields[0])
+		if err != nil {
+			return err
+		}
+		n, err := strconv.Atoi(fields[1])
+		if err != nil {
+			return err
+		}
+		if s.StatusCodes == nil {
+			s.StatusCodes = map[int]int{}
+		}
+		s.StatusCodes[code] = n
+	default:
+		p, err := parsePercentile(data)
+		if err != nil {
+			return err
+		}
+		s.Percentiles = append(s.Percentiles, p)
+	}
+	return nil
+}
+
+func parsePercentile(data string) (Percentile, error) {
+	fields := strings.SplitN(data, "=", 2)
+	if len(fields) != 2 || !strings.HasPrefix(fields[0], "P") {
+		return Percentile{}, fmt.Errorf("invalid percentile %q", data)
+	}
+	p, err := strconv.ParseFloat(strings.TrimPrefix(fields[0], "P"), 64)
+	if err != nil {
+		return Percentile{}, err
+	}
+	value, err := strconv.ParseFloat(fields[1], 64)
+	if err != nil {
+		return Percentile{}, err
+	}
+	return Percentile{P: p, Value: value}, nil
+}
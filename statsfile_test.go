@@ -0,0 +1,295 @@
+package bench_test
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/thiagonache/bench"
+)
+
+func fullStats() bench.Stats {
+	return bench.Stats{
+		URL:                  "http://fake.url/search?q=a,b",
+		Mean:                 12.5,
+		Min:                  1.25,
+		Max:                  80,
+		StdDev:               3.125,
+		P50:                  10,
+		P90:                  20,
+		P99:                  70.5,
+		CorrectedP50:         11,
+		CorrectedP90:         21,
+		CorrectedP99:         71,
+		Percentiles:          []bench.Percentile{{P: 50, Value: 10}, {P: 99.9, Value: 79}},
+		CorrectedPercentiles: []bench.Percentile{{P: 50, Value: 11}, {P: 99.9, Value: 79.5}},
+		Failures:             3,
+		Errors:               map[string]int{bench.ErrorClassTimeout: 1, bench.StatusClass(500): 2},
+		StatusCodes:          map[int]int{200: 17, 500: 2},
+		AssertionFailures:    map[string]int{bench.ReasonBody: 1},
+		Requests:             20,
+		Successes:            17,
+		RequestBytes:         100,
+		ResponseBytes:        2048,
+		Late:                 2,
+		Dropped:              1,
+		Duration:             1500 * time.Millisecond,
+		ConfiguredDuration:   time.Second,
+		Endpoints: []bench.Stats{
+			{Endpoint: "GET /a", URL: "http://fake.url/a", Requests: 10, Successes: 10, P50: 9},
+			{Endpoint: "POST /b", URL: "http://fake.url/b", Requests: 10, Successes: 7, Failures: 3},
+		},
+		Stages: []bench.Stats{
+			{Stage: 1, Requests: 5, Successes: 5, ConfiguredDuration: time.Second, Duration: time.Second},
+			{Stage: 2, Requests: 15, Successes: 12, Failures: 3, ConfiguredDuration: time.Second, Duration: 500 * time.Millisecond},
+		},
+		Phases: []bench.PhaseStats{
+			{Phase: bench.PhaseConnect, Count: 2, Mean: 0.5, Percentiles: []bench.Percentile{{P: 50, Value: 0.5}}},
+			{Phase: bench.PhaseTTFB, Count: 20, Mean: 9.5, Percentiles: []bench.Percentile{{P: 50, Value: 9}}},
+		},
+		Warmup:  &bench.Stats{Requests: 4, Successes: 4, P50: 30, Duration: 200 * time.Millisecond},
+		Samples: []float64{1.25, 10, 80},
+	}
+}
+
+func fullStatsFile() bench.StatsFile {
+	return bench.StatsFile{
+		Version: bench.StatsFileVersion,
+		Metadata: bench.RunMetadata{
+			StartedAt: time.Date(2022, 3, 1, 10, 0, 0, 0, time.UTC),
+			Hostname:  "bench-host",
+			GoVersion: "go1.17",
+			UserAgent: bench.DefaultUserAgent,
+		},
+		Config: bench.RunConfig{
+			URL:         "http://fake.url/search?q=a,b",
+			Method:      http.MethodGet,
+			Concurrency: 4,
+			Requests:    20,
+			Percentiles: []float64{50, 99.9},
+			Stages:      []bench.Stage{{Duration: time.Second, Target: 4}},
+		},
+		Stats: []bench.Stats{fullStats()},
+	}
+}
+
+func TestWriteStatsFileJSONAndDecodeStatsFileRoundTrip(t *testing.T) {
+	t.Parallel()
+	want := fullStatsFile()
+	want.Stats[0].TimeSeries = []bench.IntervalStats{{Start: time.Second, Requests: 20, RequestsPerSecond: 20}}
+	output := &bytes.Buffer{}
+	err := bench.WriteStatsFileJSON(output, want)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := bench.DecodeStatsFile(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !cmp.Equal(want, got) {
+		t.Error(cmp.Diff(want, got))
+	}
+}
+
+func TestWriteStatsFileCSVAndDecodeStatsFileRoundTrip(t *testing.T) {
+	t.Parallel()
+	second := bench.Stats{URL: "http://other.url", Requests: 1, Successes: 1, P50: 3}
+	want := fullStatsFile()
+	want.Stats = append(want.Stats, second)
+	output := &bytes.Buffer{}
+	err := bench.WriteStatsFileCSV(output, want)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(output.String(), "# simplebench stats v1\n") {
+		t.Errorf("want CSV stats file to start with its version, got %q", output.String())
+	}
+	got, err := bench.DecodeStatsFile(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !cmp.Equal(want, got) {
+		t.Error(cmp.Diff(want, got))
+	}
+}
+
+func TestDecodeStatsFile_ReadsLegacyFormat(t *testing.T) {
+	t.Parallel()
+	input := "http://fake.url,20,19,1,100.123,150,198.465,90,10,200,12.5,P75=120,error:timeout=1,status:200=19\n" +
+		"http://other.url,5,5,0,1,2,3\n"
+	want := bench.StatsFile{
+		Stats: []bench.Stats{
+			{
+				URL:         "http://fake.url",
+				Requests:    20,
+				Successes:   19,
+				Failures:    1,
+				P50:         100.123,
+				P90:         150,
+				P99:         198.465,
+				Mean:        90,
+				Min:         10,
+				Max:         200,
+				StdDev:      12.5,
+				Percentiles: []bench.Percentile{{P: 75, Value: 120}},
+				Errors:      map[string]int{bench.ErrorClassTimeout: 1},
+				StatusCodes: map[int]int{200: 19},
+			},
+			{
+				URL:       "http://other.url",
+				Requests:  5,
+				Successes: 5,
+				P50:       1,
+				P90:       2,
+				P99:       3,
+			},
+		},
+	}
+	got, err := bench.DecodeStatsFile(strings.NewReader(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !cmp.Equal(want, got) {
+		t.Error(cmp.Diff(want, got))
+	}
+}
+
+func TestDecodeStatsFile_ReadsLegacyURLsWithCommas(t *testing.T) {
+	t.Parallel()
+	input := "http://x/?a=1,2,20,19,1,100,150,198\n" +
+		"http://x/?a=1,2,20,19,1,100,150,198,90,10,200,12.5,P75=120\n"
+	got, err := bench.DecodeStatsFile(strings.NewReader(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []bench.Stats{
+		{URL: "http://x/?a=1,2", Requests: 20, Successes: 19, Failures: 1, P50: 100, P90: 150, P99: 198},
+		{
+			URL:         "http://x/?a=1,2",
+			Requests:    20,
+			Successes:   19,
+			Failures:    1,
+			P50:         100,
+			P90:         150,
+			P99:         198,
+			Mean:        90,
+			Min:         10,
+			Max:         200,
+			StdDev:      12.5,
+			Percentiles: []bench.Percentile{{P: 75, Value: 120}},
+		},
+	}
+	if !cmp.Equal(want, got.Stats) {
+		t.Error(cmp.Diff(want, got.Stats))
+	}
+}
+
+func TestDecodeStatsFile_ErrorsWithLineNumbers(t *testing.T) {
+	t.Parallel()
+	tcs := []struct {
+		name, input, want string
+	}{
+		{
+			name:  "short legacy line",
+			input: "http://fake.url,20,19,1,100,150,198\nhttp://fake.url,20\n",
+			want:  "line 2: want at least 7 fields, got 2",
+		},
+		{
+			name:  "invalid legacy count",
+			input: "http://fake.url,many,19,1,100,150,198\n",
+			want:  "line 1: field 2",
+		},
+		{
+			name:  "invalid JSON",
+			input: "{\n  \"Version\": 1,\n  \"Stats\": [\n    {\"Requests\": \"many\"}\n  ]\n}\n",
+			want:  "line 4:",
+		},
+		{
+			name:  "unsupported JSON version",
+			input: `{"Version": 99}`,
+			want:  "unsupported stats file version 99",
+		},
+		{
+			name:  "CSV without column",
+			input: "# simplebench stats v1\nentry,url\n",
+			want:  "line 2: header has no endpoint column",
+		},
+		{
+			name: "invalid CSV value",
+			input: "# simplebench stats v1\n" +
+				"entry,url,endpoint,stage,requests,successes,failures,mean,min,max,stddev,p50,p90,p99,corrected_p50,corrected_p90,corrected_p99,late,dropped,request_bytes,response_bytes,duration,configured_duration\n" +
+				"run,http://fake.url,,0,20,20,0,1,1,1,0,1,1,1,0,0,0,0,0,0,0,1s,0s\n" +
+				"run,http://fake.url,,0,20,20,0,1,1,1,0,1,1,1,0,0,0,0,0,0,0,soon,0s\n",
+			want: "line 4: column duration",
+		},
+		{
+			name:  "empty",
+			input: "\n",
+			want:  bench.ErrEmptyStatsFile.Error(),
+		},
+	}
+	for _, tc := range tcs {
+		_, err := bench.DecodeStatsFile(strings.NewReader(tc.input))
+		if err == nil {
+			t.Errorf("%s: want error", tc.name)
+			continue
+		}
+		if !strings.Contains(err.Error(), tc.want) {
+			t.Errorf("%s: want error containing %q, got %q", tc.name, tc.want, err)
+		}
+	}
+}
+
+func TestRun_ExportsStatsFileWithMetadataAndConfig(t *testing.T) {
+	t.Parallel()
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+	for _, format := range []string{bench.FormatJSON, bench.FormatCSV} {
+		dir := t.TempDir()
+		tester, err := bench.NewTester(
+			bench.WithURL(server.URL),
+			bench.WithRequests(3),
+			bench.WithExportStats(true),
+			bench.WithStatsFormat(format),
+			bench.WithOutputPath(dir),
+			bench.WithStdout(io.Discard),
+			bench.WithStderr(io.Discard),
+		)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = tester.Run()
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := bench.ReadStatsFilePath(dir + "/stats." + format)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.Version != bench.StatsFileVersion || got.Metadata.StartedAt.IsZero() || got.Metadata.GoVersion == "" {
+			t.Errorf("%s: want version and metadata, got %+v", format, got)
+		}
+		if got.Config.URL != server.URL || got.Config.Requests != 3 || got.Config.Concurrency != 1 {
+			t.Errorf("%s: want configuration, got %+v", format, got.Config)
+		}
+		if len(got.Stats) != 1 || got.Stats[0].Requests != 3 {
+			t.Errorf("%s: want stats of 3 requests, got %+v", format, got.Stats)
+		}
+	}
+}
+
+func TestFromArgs_ErrorsIfStatsFormatIsInvalid(t *testing.T) {
+	t.Parallel()
+	_, err := bench.NewTester(
+		bench.FromArgs([]string{"run", "-u", "http://fake.url", "-stats-format", "xml"}),
+		bench.WithStderr(io.Discard),
+	)
+	if err == nil {
+		t.Error("want error")
+	}
+}
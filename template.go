@@ -0,0 +1,226 @@
+package bench
+
+import (
+	"encoding/csv"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"text/template"
+)
+
+// TemplateData is available to URL, header and body templates. Seq is the
+// request number, starting at 0, and Row is the data file row it was given,
+// keyed by column name.
+type TemplateData struct {
+	Seq int
+	Row map[string]string
+}
+
+// ReadTemplateDataFile reads a CSV file whose first line names the columns.
+// Requests take its rows in turn, wrapping around at the end.
+func ReadTemplateDataFile(path string) ([]map[string]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	records, err := csv.NewReader(f).ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("reading template data: %w", err)
+	}
+	if len(records) < 2 {
+		return nil, fmt.Errorf("reading template data: %s has no rows", path)
+	}
+	rows := make([]map[string]string, 0, len(records)-1)
+	for _, record := range records[1:] {
+		row := map[string]string{}
+		for i, column := range records[0] {
+			row[column] = record[i]
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+func WithTemplateData(rows []map[string]string) Option {
+	return func(t *Tester) error {
+		t.templateData = rows
+		return nil
+	}
+}
+
+func WithTemplateDataFile(path string) Option {
+	return func(t *Tester) error {
+		rows, err := ReadTemplateDataFile(path)
+		if err != nil {
+			return err
+		}
+		t.templateData = rows
+		return nil
+	}
+}
+
+// WithSeed makes the random values of templates reproducible: a request
+// renders the same way for the same seed and Seq, whichever worker sends it.
+// It also seeds the random order of scenario endpoints.
+func WithSeed(seed int64) Option {
+	return func(t *Tester) error {
+		t.seed = seed
+		return nil
+	}
+}
+
+func (t Tester) Seed() int64 {
+	return t.seed
+}
+
+// renderer renders the templates of one worker, so template functions can
+// share its random state without locking.
+type renderer struct {
+	tester    *Tester
+	data      TemplateData
+	rand      splitMix64
+	templates map[string]*template.Template
+}
+
+func (t *Tester) newRenderer() *renderer {
+	return &renderer{
+		tester:    t,
+		templates: map[string]*template.Template{},
+	}
+}
+
+// newRequest renders the endpoint URL, body and every header value for the
+// request with sequence number seq.
+func (r *renderer) newRequest(e Endpoint, seq int) (*http.Request, error) {
+	r.data = TemplateData{Seq: seq}
+	if len(r.tester.templateData) > 0 {
+		r.data.Row = r.tester.templateData[seq%len(r.tester.templateData)]
+	}
+	// Stepping the seed by seq would replay the draws of the previous
+	// request shifted by one, so seed and seq are mixed instead.
+	r.rand = splitMix64(mix64(uint64(r.tester.seed) ^ mix64(uint64(seq))))
+	var err error
+	e.URL, err = r.render(e.URL)
+	if err != nil {
+		return nil, err
+	}
+	e.Body, err = r.render(e.Body)
+	if err != nil {
+		return nil, err
+	}
+	req, err := r.tester.NewRequest(e)
+	if err != nil {
+		return nil, err
+	}
+	for key, values := range req.Header {
+		rendered := make([]string, len(values))
+		for i, v := range values {
+			rendered[i], err = r.render(v)
+			if err != nil {
+				return nil, err
+			}
+		}
+		req.Header[key] = rendered
+	}
+	return req, nil
+}
+
+func (r *renderer) render(text string) (string, error) {
+	if !strings.Contains(text, "{{") {
+		return text, nil
+	}
+	tmpl, err := r.parse(text)
+	if err != nil {
+		return "", err
+	}
+	b := &strings.Builder{}
+	err = tmpl.Execute(b, r.data)
+	if err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
+
+func (r *renderer) parse(text string) (*template.Template, error) {
+	tmpl, ok := r.templates[text]
+	if ok {
+		return tmpl, nil
+	}
+	tmpl, err := template.New("request").Option("missingkey=error").Funcs(template.FuncMap{
+		"randInt": r.randInt,
+		"uuid":    r.uuid,
+	}).Parse(text)
+	if err != nil {
+		return nil, err
+	}
+	r.templates[text] = tmpl
+	return tmpl, nil
+}
+
+// randInt returns a random number between min and max, inclusive.
+func (r *renderer) randInt(min, max int) (int, error) {
+	if max < min {
+		return 0, fmt.Errorf("randInt: max %d is lower than min %d", max, min)
+	}
+	return min + int(r.rand.next()%uint64(max-min+1)), nil
+}
+
+// uuid returns a random (version 4) UUID.
+func (r *renderer) uuid() string {
+	b := make([]byte, 16)
+	for i := 0; i < len(b); i += 8 {
+		v := r.rand.next()
+		for j := 0; j < 8; j++ {
+			b[i+j] = byte(v >> (8 * j))
+		}
+	}
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16])
+}
+
+// validateTemplates parses every template requests can use, so mistakes
+// are reported before the run starts.
+func (t *Tester) validateTemplates() error {
+	r := t.newRenderer()
+	texts := []string{}
+	for _, endpoints := range [][]Endpoint{t.scenario.Endpoints, t.replay} {
+		for _, e := range endpoints {
+			texts = append(texts, e.URL, e.Body)
+			for _, v := range e.Headers {
+				texts = append(texts, v)
+			}
+		}
+	}
+	for _, values := range t.header {
+		texts = append(texts, values...)
+	}
+	for _, text := range texts {
+		if !strings.Contains(text, "{{") {
+			continue
+		}
+		_, err := r.parse(text)
+		if err != nil {
+			return fmt.Errorf("invalid template %q: %w", text, err)
+		}
+	}
+	return nil
+}
+
+// splitMix64 is a small, fast random generator, cheap enough to seed afresh
+// for every request.
+type splitMix64 uint64
+
+func (s *splitMix64) next() uint64 {
+	*s += 0x9e3779b97f4a7c15
+	return mix64(uint64(*s))
+}
+
+// mix64 is the finalizer of splitMix64, which scrambles the bits of z.
+func mix64(z uint64) uint64 {
+	z = (z ^ (z >> 30)) * 0xbf58476d1ce4e5b9
+	z = (z ^ (z >> 27)) * 0x94d049bb133111eb
+	return z ^ (z >> 31)
+}
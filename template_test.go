@@ -0,0 +1,197 @@
+package bench_test
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/thiagonache/bench"
+)
+
+// recordRequests runs the tester against a server that returns what each
+// request sent, as its method, path, X-Test header and body.
+func recordRequests(t *testing.T, opts ...bench.Option) []string {
+	t.Helper()
+	var mu sync.Mutex
+	got := []string{}
+	server := httptest.NewTLSServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			t.Error(err)
+		}
+		mu.Lock()
+		got = append(got, r.URL.RequestURI()+" "+r.Header.Get("X-Test")+" "+string(body))
+		mu.Unlock()
+	}))
+	defer server.Close()
+	opts = append([]bench.Option{
+		bench.WithURL(server.URL + "/"),
+		bench.WithHTTPClient(server.Client()),
+		bench.WithStdout(io.Discard),
+		bench.WithStderr(io.Discard),
+	}, opts...)
+	tester, err := bench.NewTester(opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = tester.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return got
+}
+
+func TestRunRendersSeqInURLHeadersAndBody(t *testing.T) {
+	t.Parallel()
+	got := recordRequests(t,
+		bench.WithScenario(bench.Scenario{
+			Endpoints: []bench.Endpoint{
+				{
+					Method:  http.MethodPost,
+					URL:     "https://example.com/items/{{.Seq}}",
+					Headers: map[string]string{"X-Test": "h{{.Seq}}"},
+					Body:    "b{{.Seq}}",
+				},
+			},
+		}),
+		bench.WithRequests(3),
+		bench.WithConcurrency(3),
+	)
+	sort.Strings(got)
+	want := []string{
+		"/items/0 h0 b0",
+		"/items/1 h1 b1",
+		"/items/2 h2 b2",
+	}
+	if !cmp.Equal(want, got) {
+		t.Error(cmp.Diff(want, got))
+	}
+}
+
+func TestRunRendersSeqFromZeroAfterWarmup(t *testing.T) {
+	t.Parallel()
+	got := recordRequests(t,
+		bench.WithScenario(bench.Scenario{
+			Endpoints: []bench.Endpoint{{URL: "https://example.com/items/{{.Seq}}"}},
+		}),
+		bench.WithWarmupRequests(3),
+		bench.WithRequests(2),
+	)
+	want := []string{
+		"/items/0  ",
+		"/items/1  ",
+		"/items/2  ",
+		"/items/0  ",
+		"/items/1  ",
+	}
+	if !cmp.Equal(want, got) {
+		t.Error(cmp.Diff(want, got))
+	}
+}
+
+func TestRunRendersRandomValuesReproduciblyForSeed(t *testing.T) {
+	t.Parallel()
+	opts := []bench.Option{
+		bench.WithHTTPMethod(http.MethodPost),
+		bench.WithBody([]byte(`{{randInt 1 1000}} {{uuid}}`)),
+		bench.WithRequests(5),
+	}
+	first := recordRequests(t, append(opts, bench.WithSeed(42))...)
+	second := recordRequests(t, append(opts, bench.WithSeed(42))...)
+	if !cmp.Equal(first, second) {
+		t.Error(cmp.Diff(first, second))
+	}
+	other := recordRequests(t, append(opts, bench.WithSeed(7))...)
+	if cmp.Equal(first, other) {
+		t.Error("want different requests for a different seed")
+	}
+	body := regexp.MustCompile(`^/  ([0-9]{1,4}) [0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)
+	for _, r := range first {
+		if !body.MatchString(r) {
+			t.Errorf("want random number and UUID, got %q", r)
+		}
+	}
+}
+
+func TestRunRendersDistinctRandomValuesForConsecutiveRequests(t *testing.T) {
+	t.Parallel()
+	got := recordRequests(t,
+		bench.WithHTTPMethod(http.MethodPost),
+		bench.WithBody([]byte(`{{.Seq}} {{randInt 1 1000000}} {{randInt 1 1000000}} {{randInt 1 1000000}}`)),
+		bench.WithRequests(20),
+		bench.WithSeed(7),
+	)
+	draws := map[int][]string{}
+	for _, r := range got {
+		fields := strings.Fields(r)
+		seq, err := strconv.Atoi(fields[1])
+		if err != nil {
+			t.Fatal(err)
+		}
+		draws[seq] = fields[2:]
+	}
+	for seq := 0; seq < 19; seq++ {
+		for _, v := range draws[seq] {
+			for _, next := range draws[seq+1] {
+				if v == next {
+					t.Errorf("request %d drew %s, as did request %d: %v and %v", seq+1, v, seq, draws[seq], draws[seq+1])
+				}
+			}
+		}
+	}
+}
+
+func TestRunRendersTemplateDataRowsInTurn(t *testing.T) {
+	t.Parallel()
+	rows, err := bench.ReadTemplateDataFile("testdata/users.csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := recordRequests(t,
+		bench.WithTemplateData(rows),
+		bench.WithHTTPHeader("X-Test", "{{.Row.name}}"),
+		bench.WithHTTPMethod(http.MethodPost),
+		bench.WithBody([]byte("{{.Row.email}}")),
+		bench.WithRequests(3),
+	)
+	want := []string{
+		"/ alice alice@example.com",
+		"/ bob bob@example.com",
+		"/ alice alice@example.com",
+	}
+	if !cmp.Equal(want, got) {
+		t.Error(cmp.Diff(want, got))
+	}
+}
+
+func TestNewTesterWithInvalidTemplateReturnsError(t *testing.T) {
+	t.Parallel()
+	_, err := bench.NewTester(
+		bench.WithURL("https://example.com/{{.Seq"),
+		bench.WithStderr(io.Discard),
+	)
+	if err == nil {
+		t.Fatal("want error for invalid template")
+	}
+}
+
+func TestFromArgsSeedAndDataFlagsConfigureTemplates(t *testing.T) {
+	t.Parallel()
+	tester, err := bench.NewTester(
+		bench.FromArgs([]string{"run", "-u", "https://example.com/{{.Row.name}}", "-seed", "42", "-data", "testdata/users.csv"}),
+		bench.WithStderr(io.Discard),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tester.Seed() != 42 {
+		t.Errorf("want seed 42, got %d", tester.Seed())
+	}
+}
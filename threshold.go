@@ -0,0 +1,288 @@
+package bench
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var ErrThresholdsFailed = errors.New("thresholds failed")
+
+// Threshold is a condition the stats of a run must meet, such as p95<200ms,
+// errors<0.5% or rps>1000. Metric is a latency percentile (p50, p99.9), mean,
+// min, max, errors (the error rate), failures (the number of failed
+// requests) or rps. Limit is in milliseconds for latencies and a fraction for
+// the error rate.
+type Threshold struct {
+	Expr   string
+	Metric string
+	Op     string
+	Limit  float64
+}
+
+// ParseThreshold parses an expression made of a metric, one of <, <=, > or
+// >=, and a limit. Latency limits are durations, or milliseconds if they
+// have no unit, and error rates are percentages or fractions.
+func ParseThreshold(expr string) (Threshold, error) {
+	s := strings.ReplaceAll(expr, " ", "")
+	i := strings.IndexAny(s, "<>")
+	if i < 1 {
+		return Threshold{}, fmt.Errorf("invalid threshold %q, want metric, comparison and limit (e.g. p95<200ms)", expr)
+	}
+	th := Threshold{
+		Expr:   expr,
+		Metric: strings.ToLower(s[:i]),
+		Op:     s[i : i+1],
+	}
+	value := s[i+1:]
+	if strings.HasPrefix(value, "=") {
+		th.Op += "="
+		value = value[1:]
+	}
+	var err error
+	switch {
+	case th.Metric == "errors":
+		th.Limit, err = ParseRate(value)
+	case th.Metric == "failures", th.Metric == "rps":
+		th.Limit, err = strconv.ParseFloat(value, 64)
+	case th.latency():
+		if p, ok := th.percentile(); ok && (p <= 0 || p > 100) {
+			return Threshold{}, fmt.Errorf("invalid threshold %q: %v is invalid percentile", expr, p)
+		}
+		th.Limit, err = parseLatency(value)
+	default:
+		return Threshold{}, fmt.Errorf("invalid threshold %q: unknown metric %q, want a percentile such as p95, mean, min, max, errors, failures or rps", expr, th.Metric)
+	}
+	if err != nil {
+		return Threshold{}, fmt.Errorf("invalid threshold %q: %w", expr, err)
+	}
+	return th, nil
+}
+
+func parseLatency(s string) (float64, error) {
+	v, err := strconv.ParseFloat(s, 64)
+	if err == nil {
+		return v, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid latency %q", s)
+	}
+	return milliseconds(d), nil
+}
+
+// percentile returns the percentile of a metric such as p95.
+func (th Threshold) percentile() (float64, bool) {
+	if !strings.HasPrefix(th.Metric, "p") {
+		return 0, false
+	}
+	p, err := strconv.ParseFloat(th.Metric[1:], 64)
+	if err != nil {
+		return 0, false
+	}
+	return p, true
+}
+
+func (th Threshold) latency() bool {
+	_, ok := th.percentile()
+	return ok || th.Metric == "mean" || th.Metric == "min" || th.Metric == "max"
+}
+
+// Value returns the metric of the threshold in stats, or NaN if stats lack
+// the percentile.
+func (th Threshold) Value(stats Stats) float64 {
+	switch th.Metric {
+	case "mean":
+		return stats.Mean
+	case "min":
+		return stats.Min
+	case "max":
+		return stats.Max
+	case "errors":
+		if stats.Requests == 0 {
+			return 0
+		}
+		return float64(stats.Failures) / float64(stats.Requests)
+	case "failures":
+		return float64(stats.Failures)
+	case "rps":
+		return stats.RequestsPerSecond()
+	case "p50":
+		return stats.P50
+	case "p90":
+		return stats.P90
+	case "p99":
+		return stats.P99
+	}
+	p, _ := th.percentile()
+	v, ok := stats.Percentile(p)
+	if !ok {
+		return math.NaN()
+	}
+	return v
+}
+
+// Met reports whether value meets the threshold.
+func (th Threshold) Met(value float64) bool {
+	switch th.Op {
+	case "<":
+		return value < th.Limit
+	case "<=":
+		return value <= th.Limit
+	case ">":
+		return value > th.Limit
+	case ">=":
+		return value >= th.Limit
+	}
+	return false
+}
+
+func (th Threshold) format(value float64) string {
+	switch {
+	case math.IsNaN(value):
+		return "not recorded"
+	case th.Metric == "errors":
+		return fmt.Sprintf("%.2f%%", value*100)
+	case th.Metric == "failures":
+		return strconv.FormatFloat(value, 'f', 0, 64)
+	case th.Metric == "rps":
+		return fmt.Sprintf("%.2f req/s", value)
+	}
+	return fmt.Sprintf("%.3fms", value)
+}
+
+// ThresholdResult is the outcome of checking a threshold against a run.
+type ThresholdResult struct {
+	Threshold Threshold
+	Value     float64
+	Pass      bool
+}
+
+func (r ThresholdResult) String() string {
+	outcome := "FAIL"
+	if r.Pass {
+		outcome = "PASS"
+	}
+	return fmt.Sprintf("Threshold %s: %s (%s %s)", r.Threshold.Expr, outcome, r.Threshold.Metric, r.Threshold.format(r.Value))
+}
+
+// CheckThresholds checks every threshold against stats.
+func CheckThresholds(stats Stats, thresholds []Threshold) []ThresholdResult {
+	results := make([]ThresholdResult, len(thresholds))
+	for i, th := range thresholds {
+		v := th.Value(stats)
+		results[i] = ThresholdResult{
+			Threshold: th,
+			Value:     v,
+			Pass:      th.Met(v),
+		}
+	}
+	return results
+}
+
+// WithThresholds makes Run print whether the run met each threshold, and
+// return ErrThresholdsFailed if it missed any. Percentiles missing from the
+// reported ones are added to them.
+func WithThresholds(thresholds ...Threshold) Option {
+	return func(t *Tester) error {
+		t.thresholds = append(t.thresholds, thresholds...)
+		return nil
+	}
+}
+
+// WithThresholdAbort aborts the run as soon as a threshold can no longer be
+// met whatever the remaining requests do. Failures and max are checked in
+// any run; errors and percentiles only when the number of requests is known
+// in advance, not when running for a duration. Other thresholds are only
+// checked at the end.
+func WithThresholdAbort(abort bool) Option {
+	return func(t *Tester) error {
+		t.thresholdAbort = abort
+		return nil
+	}
+}
+
+func (t Tester) Thresholds() []Threshold {
+	return t.thresholds
+}
+
+// setupThresholds adds the percentiles of the thresholds to the reported
+// ones, keeping them sorted and without duplicates.
+func (t *Tester) setupThresholds() {
+	percentiles := append([]float64{}, t.percentiles...)
+	for _, th := range t.thresholds {
+		p, ok := th.percentile()
+		if !ok || p == 50 || p == 90 || p == 99 || containsFloat(percentiles, p) {
+			continue
+		}
+		percentiles = append(percentiles, p)
+	}
+	sort.Float64s(percentiles)
+	t.percentiles = percentiles
+	t.overThreshold = make([]int, len(t.thresholds))
+}
+
+func containsFloat(values []float64, v float64) bool {
+	for _, x := range values {
+		if x == v {
+			return true
+		}
+	}
+	return false
+}
+
+// recordThresholds counts the responses whose latency would break each
+// latency threshold, if the run is to abort early.
+func (t *Tester) recordThresholds(latency float64) {
+	if !t.thresholdAbort {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for i, th := range t.thresholds {
+		if th.latency() && !th.Met(latency) {
+			t.overThreshold[i]++
+		}
+	}
+}
+
+// checkThresholds aborts the run if a threshold with an upper limit can no
+// longer be met. The total number of requests is only known for runs of a
+// fixed number of requests.
+func (t *Tester) checkThresholds() {
+	if !t.thresholdAbort {
+		return
+	}
+	total := 0
+	if t.duration == 0 && len(t.replay) == 0 {
+		total = t.requests
+	}
+	t.mu.Lock()
+	failures := t.stats.Failures
+	over := append([]int{}, t.overThreshold...)
+	t.mu.Unlock()
+	for i, th := range t.thresholds {
+		if th.Op != "<" && th.Op != "<=" {
+			continue
+		}
+		breached := false
+		switch p, isPercentile := th.percentile(); {
+		case th.Metric == "failures":
+			breached = !th.Met(float64(failures))
+		case th.Metric == "max":
+			breached = over[i] > 0
+		case th.Metric == "errors" && total > 0:
+			breached = !th.Met(float64(failures) / float64(total))
+		case isPercentile && total > 0:
+			breached = over[i] > total-int(math.Ceil(p/100*float64(total)))
+		}
+		if breached {
+			t.Abort(fmt.Sprintf("threshold %s can no longer be met", th.Expr))
+			return
+		}
+	}
+}
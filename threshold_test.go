@@ -0,0 +1,180 @@
+package bench_test
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/thiagonache/bench"
+)
+
+func TestParseThreshold_ParsesMetricComparisonAndLimit(t *testing.T) {
+	t.Parallel()
+	tcs := map[string]bench.Threshold{
+		"p95<200ms":    {Expr: "p95<200ms", Metric: "p95", Op: "<", Limit: 200},
+		"P99.9 <= 1s":  {Expr: "P99.9 <= 1s", Metric: "p99.9", Op: "<=", Limit: 1000},
+		"mean<12.5":    {Expr: "mean<12.5", Metric: "mean", Op: "<", Limit: 12.5},
+		"errors<0.5%":  {Expr: "errors<0.5%", Metric: "errors", Op: "<", Limit: 0.005},
+		"failures<=10": {Expr: "failures<=10", Metric: "failures", Op: "<=", Limit: 10},
+		"rps>1000":     {Expr: "rps>1000", Metric: "rps", Op: ">", Limit: 1000},
+		"rps>=1e3":     {Expr: "rps>=1e3", Metric: "rps", Op: ">=", Limit: 1000},
+	}
+	for expr, want := range tcs {
+		got, err := bench.ParseThreshold(expr)
+		if err != nil {
+			t.Errorf("%s: %v", expr, err)
+			continue
+		}
+		if !cmp.Equal(want, got) {
+			t.Errorf("%s: %s", expr, cmp.Diff(want, got))
+		}
+	}
+}
+
+func TestParseThreshold_ErrorsForInvalidExpressions(t *testing.T) {
+	t.Parallel()
+	for _, expr := range []string{"", "p95", "<200ms", "p95=200ms", "latency<200ms", "p95<soon", "p101<1s", "errors<lots", "rps>many"} {
+		_, err := bench.ParseThreshold(expr)
+		if err == nil {
+			t.Errorf("want error for %q", expr)
+		}
+	}
+}
+
+func TestCheckThresholds_ReportsWhetherEachThresholdIsMet(t *testing.T) {
+	t.Parallel()
+	stats := bench.Stats{
+		P50:         10,
+		P99:         150,
+		Percentiles: []bench.Percentile{{P: 95, Value: 120}},
+		Requests:    1000,
+		Failures:    10,
+		Duration:    time.Second,
+	}
+	thresholds := []bench.Threshold{}
+	for _, expr := range []string{"p95<200ms", "p99<100ms", "errors<0.5%", "rps>500", "failures<=10"} {
+		th, err := bench.ParseThreshold(expr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		thresholds = append(thresholds, th)
+	}
+	want := []string{
+		"Threshold p95<200ms: PASS (p95 120.000ms)",
+		"Threshold p99<100ms: FAIL (p99 150.000ms)",
+		"Threshold errors<0.5%: FAIL (errors 1.00%)",
+		"Threshold rps>500: PASS (rps 1000.00 req/s)",
+		"Threshold failures<=10: PASS (failures 10)",
+	}
+	got := []string{}
+	for _, r := range bench.CheckThresholds(stats, thresholds) {
+		got = append(got, r.String())
+	}
+	if !cmp.Equal(want, got) {
+		t.Error(cmp.Diff(want, got))
+	}
+}
+
+func TestRun_PrintsThresholdsAndErrorsIfAnyFails(t *testing.T) {
+	t.Parallel()
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+	stdout := &bytes.Buffer{}
+	tester, err := bench.NewTester(
+		bench.FromArgs([]string{"run", "-r", "20", "-threshold", "p95<10s", "-threshold", "rps<1", "-u", server.URL}),
+		bench.WithStdout(stdout),
+		bench.WithStderr(io.Discard),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = tester.Run()
+	if !errors.Is(err, bench.ErrThresholdsFailed) {
+		t.Errorf("want ErrThresholdsFailed, got %v", err)
+	}
+	if !strings.Contains(stdout.String(), "Threshold p95<10s: PASS (p95 ") {
+		t.Errorf("want passing p95 threshold printed, got:\n%s", stdout)
+	}
+	if !strings.Contains(stdout.String(), "Threshold rps<1: FAIL (rps ") {
+		t.Errorf("want failing rps threshold printed, got:\n%s", stdout)
+	}
+	if !strings.Contains(stdout.String(), "P95: ") {
+		t.Errorf("want P95 added to the reported percentiles, got:\n%s", stdout)
+	}
+}
+
+func TestNewTester_AddsThresholdPercentilesInOrder(t *testing.T) {
+	t.Parallel()
+	tester, err := bench.NewTester(
+		bench.FromArgs([]string{"run", "-u", "http://fake.url", "-p", "95,50", "-threshold", "p99.9<1s", "-threshold", "p75<1s", "-threshold", "p95<1s", "-threshold", "p75<2s"}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []float64{50, 75, 95, 99.9}
+	got := tester.Percentiles()
+	if !cmp.Equal(want, got) {
+		t.Error(cmp.Diff(want, got))
+	}
+}
+
+func TestRun_AbortsOnceThresholdCanNoLongerBeMet(t *testing.T) {
+	t.Parallel()
+	var requests int64
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		atomic.AddInt64(&requests, 1)
+		rw.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+	th, err := bench.ParseThreshold("errors<10%")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tester, err := bench.NewTester(
+		bench.WithURL(server.URL),
+		bench.WithRequests(1000),
+		bench.WithThresholds(th),
+		bench.WithThresholdAbort(true),
+		bench.WithStdout(io.Discard),
+		bench.WithStderr(io.Discard),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = tester.Run()
+	if !errors.Is(err, bench.ErrAborted) {
+		t.Errorf("want ErrAborted, got %v", err)
+	}
+	got := atomic.LoadInt64(&requests)
+	if got > 110 {
+		t.Errorf("want run aborted after about 100 failures, got %d requests", got)
+	}
+	if !strings.Contains(tester.AbortReason(), "errors<10%") {
+		t.Errorf("want abort reason naming the threshold, got %q", tester.AbortReason())
+	}
+}
+
+func TestRun_DoesNotAbortForThresholdsThatCanRecover(t *testing.T) {
+	t.Parallel()
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+	tester, err := bench.NewTester(
+		bench.FromArgs([]string{"run", "-d", "50ms", "-threshold", "errors<10%", "-threshold", "rps>0", "-threshold-abort", "-u", server.URL}),
+		bench.WithStdout(io.Discard),
+		bench.WithStderr(io.Discard),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = tester.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
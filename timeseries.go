@@ -0,0 +1,269 @@
+package bench
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+
+	"gonum.org/v1/plot"
+	"gonum.org/v1/plot/plotter"
+	"gonum.org/v1/plot/plotutil"
+)
+
+const (
+	FormatCSV  = "csv"
+	FormatJSON = "json"
+
+	// intervalSignificantFigures is the precision of the histogram of each
+	// interval, which is kept low as a long run has many intervals.
+	intervalSignificantFigures = 2
+)
+
+// IntervalStats are the statistics of the requests completed during one
+// interval of a run, starting Start after the run did.
+type IntervalStats struct {
+	Start             time.Duration
+	Requests          int
+	Failures          int
+	RequestsPerSecond float64
+	ErrorRate         float64
+	Mean              float64
+	Percentiles       []Percentile
+}
+
+// WithTimeSeries splits the run into intervals and reports the statistics of
+// each one, written to timeseries.csv or timeseries.json and, with graphs,
+// drawn in latency.png. Interval percentiles are accurate to two significant
+// figures, whatever the recorder of the run.
+func WithTimeSeries(interval time.Duration) Option {
+	return func(t *Tester) error {
+		if interval < 0 {
+			return fmt.Errorf("%v is invalid time series interval", interval)
+		}
+		t.seriesInterval = interval
+		return nil
+	}
+}
+
+// WithTimeSeriesFormat sets the format the time series is written in,
+// FormatCSV by default.
+func WithTimeSeriesFormat(format string) Option {
+	return func(t *Tester) error {
+		if format != FormatCSV && format != FormatJSON {
+			return fmt.Errorf("%q is invalid time series format, want %s or %s", format, FormatCSV, FormatJSON)
+		}
+		t.seriesFormat = format
+		return nil
+	}
+}
+
+func (t Tester) TimeSeriesInterval() time.Duration {
+	return t.seriesInterval
+}
+
+// timeSeries buckets requests by the interval they completed in, or started
+// in if they got no response.
+type timeSeries struct {
+	mu       *sync.Mutex
+	interval time.Duration
+	buckets  []*bucket
+}
+
+// bucket holds the requests of an interval, with a histogram of their
+// latencies created on the first response.
+type bucket struct {
+	requests int
+	failures int
+	recorder *HDRRecorder
+}
+
+func (t *Tester) recordInterval(r result) {
+	if t.timeSeries == nil {
+		return
+	}
+	s := t.timeSeries
+	completedAt := r.bodyDone
+	if !r.responded {
+		completedAt = r.startTime
+		if completedAt.IsZero() {
+			completedAt = r.scheduledAt
+		}
+	}
+	i := 0
+	if completedAt.After(t.startAt) {
+		i = int(completedAt.Sub(t.startAt) / s.interval)
+	}
+	// The length of a loaded run is known in advance, and no request falls
+	// after its last interval.
+	if last := int((t.EndAt - 1) / s.interval); t.EndAt > 0 && i > last {
+		i = last
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for len(s.buckets) <= i {
+		s.buckets = append(s.buckets, &bucket{})
+	}
+	b := s.buckets[i]
+	b.requests++
+	if r.err != nil {
+		b.failures++
+	}
+	if !r.responded {
+		return
+	}
+	if b.recorder == nil {
+		b.recorder = newHDRRecorder(intervalSignificantFigures)
+	}
+	b.recorder.RecordTime(milliseconds(r.elapsed()))
+}
+
+func (t *Tester) timeSeriesMetrics() []IntervalStats {
+	if t.timeSeries == nil {
+		return nil
+	}
+	s := t.timeSeries
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	stats := make([]IntervalStats, len(s.buckets))
+	for i, b := range s.buckets {
+		start := time.Duration(i) * s.interval
+		length := s.interval
+		if t.EndAt > start && t.EndAt-start < length {
+			length = t.EndAt - start
+		}
+		stats[i] = IntervalStats{
+			Start:             start,
+			Requests:          b.requests,
+			Failures:          b.failures,
+			RequestsPerSecond: float64(b.requests) / length.Seconds(),
+		}
+		if b.requests > 0 {
+			stats[i].ErrorRate = float64(b.failures) / float64(b.requests)
+		}
+		if b.recorder != nil {
+			stats[i].Mean = b.recorder.Mean()
+			stats[i].Percentiles = percentiles(b.recorder, t.percentiles)
+		}
+	}
+	return stats
+}
+
+// WriteTimeSeriesCSV writes a header row and a row per interval. Intervals
+// without latencies have empty percentiles.
+func WriteTimeSeriesCSV(w io.Writer, series []IntervalStats, ps []float64) error {
+	cw := csv.NewWriter(w)
+	header := []string{"start", "requests", "failures", "rps", "error_rate", "mean"}
+	for _, p := range ps {
+		header = append(header, Percentile{P: p}.Label())
+	}
+	err := cw.Write(header)
+	if err != nil {
+		return err
+	}
+	for _, s := range series {
+		row := []string{
+			strconv.FormatFloat(s.Start.Seconds(), 'f', -1, 64),
+			strconv.Itoa(s.Requests),
+			strconv.Itoa(s.Failures),
+			strconv.FormatFloat(s.RequestsPerSecond, 'f', 3, 64),
+			strconv.FormatFloat(s.ErrorRate, 'f', 4, 64),
+			strconv.FormatFloat(s.Mean, 'f', 3, 64),
+		}
+		for i := range ps {
+			value := ""
+			if i < len(s.Percentiles) {
+				value = strconv.FormatFloat(s.Percentiles[i].Value, 'f', 3, 64)
+			}
+			row = append(row, value)
+		}
+		err = cw.Write(row)
+		if err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+type intervalJSON struct {
+	Start             float64            `json:"start"`
+	Requests          int                `json:"requests"`
+	Failures          int                `json:"failures"`
+	RequestsPerSecond float64            `json:"rps"`
+	ErrorRate         float64            `json:"error_rate"`
+	Mean              float64            `json:"mean"`
+	Percentiles       map[string]float64 `json:"percentiles,omitempty"`
+}
+
+// WriteTimeSeriesJSON writes the intervals as a JSON array, with start times
+// in seconds and latencies in milliseconds.
+func WriteTimeSeriesJSON(w io.Writer, series []IntervalStats) error {
+	intervals := make([]intervalJSON, len(series))
+	for i, s := range series {
+		intervals[i] = intervalJSON{
+			Start:             s.Start.Seconds(),
+			Requests:          s.Requests,
+			Failures:          s.Failures,
+			RequestsPerSecond: s.RequestsPerSecond,
+			ErrorRate:         s.ErrorRate,
+			Mean:              s.Mean,
+		}
+		if len(s.Percentiles) > 0 {
+			intervals[i].Percentiles = map[string]float64{}
+			for _, p := range s.Percentiles {
+				intervals[i].Percentiles[p.Label()] = p.Value
+			}
+		}
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(intervals)
+}
+
+func (t *Tester) exportTimeSeries() error {
+	format := t.seriesFormat
+	if format == "" {
+		format = FormatCSV
+	}
+	file, err := os.Create(filepath.Join(t.OutputPath, "timeseries."+format))
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	if format == FormatJSON {
+		return WriteTimeSeriesJSON(file, t.stats.TimeSeries)
+	}
+	return WriteTimeSeriesCSV(file, t.stats.TimeSeries, t.percentiles)
+}
+
+// LatencyGraph draws every percentile over the intervals of the run.
+func (t Tester) LatencyGraph() error {
+	p := plot.New()
+	p.Title.Text = "Latency over time"
+	p.Y.Label.Text = "latency (ms)"
+	p.X.Label.Text = "time (s)"
+	lines := []interface{}{}
+	for i, pc := range t.percentiles {
+		xys := plotter.XYs{}
+		for _, s := range t.stats.TimeSeries {
+			if len(s.Percentiles) == 0 {
+				continue
+			}
+			xys = append(xys, plotter.XY{X: s.Start.Seconds(), Y: s.Percentiles[i].Value})
+		}
+		lines = append(lines, Percentile{P: pc}.Label(), xys)
+	}
+	err := plotutil.AddLines(p, lines...)
+	if err != nil {
+		return err
+	}
+	p.Legend.Top = true
+	p.Legend.Left = true
+	return p.Save(600, 400, filepath.Join(t.OutputPath, "latency.png"))
+}
@@ -0,0 +1,191 @@
+package bench_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/thiagonache/bench"
+)
+
+func TestRun_RecordsStatsPerInterval(t *testing.T) {
+	t.Parallel()
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		time.Sleep(time.Millisecond)
+	}))
+	defer server.Close()
+	tester, err := bench.NewTester(
+		bench.WithURL(server.URL),
+		bench.WithDuration(100*time.Millisecond),
+		bench.WithTimeSeries(25*time.Millisecond),
+		bench.WithOutputPath(t.TempDir()),
+		bench.WithStdout(io.Discard),
+		bench.WithStderr(io.Discard),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = tester.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stats := tester.Stats()
+	if len(stats.TimeSeries) < 4 {
+		t.Fatalf("want at least 4 intervals, got %d", len(stats.TimeSeries))
+	}
+	requests := 0
+	for i, s := range stats.TimeSeries {
+		want := time.Duration(i) * 25 * time.Millisecond
+		if want != s.Start {
+			t.Errorf("interval %d: want start %v, got %v", i, want, s.Start)
+		}
+		if s.Requests > 0 && (s.RequestsPerSecond <= 0 || len(s.Percentiles) != len(bench.DefaultPercentiles)) {
+			t.Errorf("interval %d: want throughput and percentiles, got %+v", i, s)
+		}
+		requests += s.Requests
+	}
+	if requests != stats.Requests {
+		t.Errorf("want interval requests to add up to %d, got %d", stats.Requests, requests)
+	}
+}
+
+func TestRun_WritesTimeSeriesAndLatencyGraph(t *testing.T) {
+	t.Parallel()
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+	dir := t.TempDir()
+	tester, err := bench.NewTester(
+		bench.WithURL(server.URL),
+		bench.WithRequests(10),
+		bench.WithTimeSeries(time.Second),
+		bench.WithTimeSeriesFormat(bench.FormatJSON),
+		bench.WithGraphs(true),
+		bench.WithOutputPath(dir),
+		bench.WithStdout(io.Discard),
+		bench.WithStderr(io.Discard),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = tester.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(dir + "/timeseries.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	intervals := []struct {
+		Start       float64            `json:"start"`
+		Requests    int                `json:"requests"`
+		Percentiles map[string]float64 `json:"percentiles"`
+	}{}
+	err = json.Unmarshal(data, &intervals)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(intervals) != 1 || intervals[0].Requests != 10 || len(intervals[0].Percentiles) != 3 {
+		t.Errorf("want one interval of 10 requests with 3 percentiles, got %s", data)
+	}
+	_, err = os.Stat(dir + "/latency.png")
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+func TestRun_BucketsLoadedResultsByTheirOwnTimes(t *testing.T) {
+	t.Parallel()
+	start := time.Date(2020, 1, 1, 10, 0, 0, 0, time.UTC)
+	result := func(offset, latency time.Duration, status int, err string) bench.Result {
+		return bench.Result{
+			Time:    start.Add(offset),
+			Method:  http.MethodGet,
+			URL:     "http://fake.url",
+			Status:  status,
+			Latency: latency,
+			Error:   err,
+		}
+	}
+	tester, err := bench.NewTester(
+		bench.WithResults([]bench.Result{
+			result(0, 10*time.Millisecond, http.StatusOK, ""),
+			result(500*time.Millisecond, 10*time.Millisecond, http.StatusOK, ""),
+			result(1200*time.Millisecond, 0, 0, bench.ErrorClassConnect),
+			result(2100*time.Millisecond, 100*time.Millisecond, http.StatusOK, ""),
+		}),
+		bench.WithTimeSeries(time.Second),
+		bench.WithOutputPath(t.TempDir()),
+		bench.WithStdout(io.Discard),
+		bench.WithStderr(io.Discard),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = tester.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := [][2]int{{2, 0}, {1, 1}, {1, 0}}
+	got := [][2]int{}
+	for _, s := range tester.Stats().TimeSeries {
+		got = append(got, [2]int{s.Requests, s.Failures})
+	}
+	if !cmp.Equal(want, got) {
+		t.Error(cmp.Diff(want, got))
+	}
+}
+
+func TestWriteTimeSeriesCSV_WritesHeaderAndRowPerInterval(t *testing.T) {
+	t.Parallel()
+	series := []bench.IntervalStats{
+		{
+			Start:             0,
+			Requests:          10,
+			Failures:          1,
+			RequestsPerSecond: 10,
+			ErrorRate:         0.1,
+			Mean:              12.5,
+			Percentiles:       []bench.Percentile{{P: 50, Value: 12}, {P: 99, Value: 20.25}},
+		},
+		{
+			Start: time.Second,
+		},
+	}
+	buf := &bytes.Buffer{}
+	err := bench.WriteTimeSeriesCSV(buf, series, []float64{50, 99})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "start,requests,failures,rps,error_rate,mean,P50,P99\n" +
+		"0,10,1,10.000,0.1000,12.500,12.000,20.250\n" +
+		"1,0,0,0.000,0.0000,0.000,,\n"
+	got := buf.String()
+	if want != got {
+		t.Error(cmp.Diff(want, got))
+	}
+}
+
+func TestFromArgs_SetsTimeSeries(t *testing.T) {
+	t.Parallel()
+	tester, err := bench.NewTester(
+		bench.FromArgs([]string{"run", "-u", "http://fake.url", "-timeseries", "1s", "-timeseries-format", "json"}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tester.TimeSeriesInterval() != time.Second {
+		t.Errorf("want time series interval 1s, got %v", tester.TimeSeriesInterval())
+	}
+	_, err = bench.NewTester(
+		bench.FromArgs([]string{"run", "-u", "http://fake.url", "-timeseries-format", "xml"}),
+	)
+	if err == nil {
+		t.Error("want error for unknown time series format")
+	}
+}
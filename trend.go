@@ -0,0 +1,237 @@
+package bench
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"gonum.org/v1/plot"
+	"gonum.org/v1/plot/plotter"
+	"gonum.org/v1/plot/plotutil"
+)
+
+// Trend follows the same run across several stats files, such as one per
+// nightly build, in order.
+type Trend struct {
+	Names []string
+	Runs  []Stats
+}
+
+// ReadTrend reads a run from each stats file, named after the file, or the
+// end of its path if other files have the same name. Files of several runs
+// contribute the one with the URL of the first run in the first file.
+func ReadTrend(paths ...string) (Trend, error) {
+	trend := Trend{}
+	url := ""
+	for i, path := range paths {
+		f, err := ReadStatsFilePath(path)
+		if err != nil {
+			return Trend{}, err
+		}
+		if len(f.Stats) == 0 {
+			return Trend{}, fmt.Errorf("%s: %w", path, ErrEmptyStatsFile)
+		}
+		if i == 0 {
+			url = f.Stats[0].URL
+		}
+		run, ok := f.Stats[0], len(f.Stats) == 1
+		for _, s := range f.Stats {
+			if s.URL == url {
+				run, ok = s, true
+				break
+			}
+		}
+		if !ok {
+			return Trend{}, fmt.Errorf("%s: no stats for %s: %w", path, url, ErrNoMatchingStats)
+		}
+		trend.Runs = append(trend.Runs, run)
+	}
+	trend.Names = trendNames(paths)
+	return trend, nil
+}
+
+// trendNames names each run after its file, with as many of its parent
+// directories as it takes to tell files of the same name apart.
+func trendNames(paths []string) []string {
+	parts := make([][]string, len(paths))
+	for i, path := range paths {
+		parts[i] = strings.Split(filepath.ToSlash(filepath.Clean(path)), "/")
+	}
+	suffix := func(i, depth int) string {
+		if depth > len(parts[i]) {
+			depth = len(parts[i])
+		}
+		return filepath.Join(parts[i][len(parts[i])-depth:]...)
+	}
+	names := make([]string, len(paths))
+	for i := range paths {
+		depth := 1
+		for ; depth < len(parts[i]); depth++ {
+			unique := true
+			for j := range paths {
+				if j != i && suffix(j, depth) == suffix(i, depth) {
+					unique = false
+					break
+				}
+			}
+			if unique {
+				break
+			}
+		}
+		names[i] = suffix(i, depth)
+	}
+	return names
+}
+
+func formatPercent(v float64) string {
+	return fmt.Sprintf("%.2f%%", v*100)
+}
+
+func errorRate(s Stats) float64 {
+	if s.Requests == 0 {
+		return 0
+	}
+	return float64(s.Failures) / float64(s.Requests)
+}
+
+// trendRow is a metric of a trend, with value returning false for runs
+// that lack it.
+type trendRow struct {
+	name   string
+	value  func(Stats) (float64, bool)
+	format func(float64) string
+}
+
+func always(f func(Stats) float64) func(Stats) (float64, bool) {
+	return func(s Stats) (float64, bool) {
+		return f(s), true
+	}
+}
+
+// rows lists every metric of the trend. Percentiles missing from some runs
+// are left blank for them, while missing errors and status codes count as
+// zero.
+func (tr Trend) rows() []trendRow {
+	rows := []trendRow{
+		{"requests", always(func(s Stats) float64 { return float64(s.Requests) }), formatCount},
+		{"successes", always(func(s Stats) float64 { return float64(s.Successes) }), formatCount},
+		{"failures", always(func(s Stats) float64 { return float64(s.Failures) }), formatCount},
+		{"error rate", always(errorRate), formatPercent},
+		{"req/s", always(Stats.RequestsPerSecond), formatRate},
+		{"mean", always(func(s Stats) float64 { return s.Mean }), formatMilliseconds},
+		{"min", always(func(s Stats) float64 { return s.Min }), formatMilliseconds},
+		{"max", always(func(s Stats) float64 { return s.Max }), formatMilliseconds},
+		{"stddev", always(func(s Stats) float64 { return s.StdDev }), formatMilliseconds},
+		{"P50", always(func(s Stats) float64 { return s.P50 }), formatMilliseconds},
+		{"P90", always(func(s Stats) float64 { return s.P90 }), formatMilliseconds},
+		{"P99", always(func(s Stats) float64 { return s.P99 }), formatMilliseconds},
+	}
+	seen := map[float64]bool{50: true, 90: true, 99: true}
+	classes := map[string]int{}
+	codes := []int{}
+	for _, run := range tr.Runs {
+		for _, p := range run.Percentiles {
+			if seen[p.P] {
+				continue
+			}
+			seen[p.P] = true
+			pc := p.P
+			rows = append(rows, trendRow{p.Label(), func(s Stats) (float64, bool) { return s.Percentile(pc) }, formatMilliseconds})
+		}
+		for class := range run.Errors {
+			classes[class]++
+		}
+		for code := range run.StatusCodes {
+			if !containsInt(codes, code) {
+				codes = append(codes, code)
+			}
+		}
+	}
+	rows = append(rows,
+		trendRow{"request bytes", always(func(s Stats) float64 { return float64(s.RequestBytes) }), formatCount},
+		trendRow{"response bytes", always(func(s Stats) float64 { return float64(s.ResponseBytes) }), formatCount},
+		trendRow{"bytes/s", always(Stats.BytesPerSecond), formatRate},
+		trendRow{"duration", always(func(s Stats) float64 { return s.Duration.Seconds() }), formatSeconds},
+	)
+	for _, class := range sortedKeys(classes) {
+		class := class
+		rows = append(rows, trendRow{"error:" + class, always(func(s Stats) float64 { return float64(s.Errors[class]) }), formatCount})
+	}
+	sort.Ints(codes)
+	for _, code := range codes {
+		code := code
+		rows = append(rows, trendRow{fmt.Sprintf("status:%d", code), always(func(s Stats) float64 { return float64(s.StatusCodes[code]) }), formatCount})
+	}
+	return rows
+}
+
+func containsInt(values []int, v int) bool {
+	for _, x := range values {
+		if x == v {
+			return true
+		}
+	}
+	return false
+}
+
+// WriteTrend writes a table of every metric with a column per run.
+func WriteTrend(w io.Writer, trend Trend) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "metric\t%s\n", strings.Join(trend.Names, "\t"))
+	for _, row := range trend.rows() {
+		cells := make([]string, len(trend.Runs))
+		for i, run := range trend.Runs {
+			cells[i] = "-"
+			if v, ok := row.value(run); ok {
+				cells[i] = row.format(v)
+			}
+		}
+		fmt.Fprintf(tw, "%s\t%s\n", row.name, strings.Join(cells, "\t"))
+	}
+	return tw.Flush()
+}
+
+// TrendGraphs draws P50, P90 and P99 across the runs of the trend to
+// trend.png in dir, and the error rate to trend-errors.png, as latencies and
+// rates do not share an axis.
+func TrendGraphs(dir string, trend Trend) error {
+	points := func(f func(Stats) float64) plotter.XYs {
+		xys := make(plotter.XYs, len(trend.Runs))
+		for i, run := range trend.Runs {
+			xys[i] = plotter.XY{X: float64(i), Y: f(run)}
+		}
+		return xys
+	}
+	p := plot.New()
+	p.Title.Text = "Latency trend"
+	p.Y.Label.Text = "latency (ms)"
+	p.X.Label.Text = "run"
+	p.NominalX(trend.Names...)
+	err := plotutil.AddLinePoints(p,
+		"P50", points(func(s Stats) float64 { return s.P50 }),
+		"P90", points(func(s Stats) float64 { return s.P90 }),
+		"P99", points(func(s Stats) float64 { return s.P99 }),
+	)
+	if err != nil {
+		return err
+	}
+	p.Legend.Top = true
+	p.Legend.Left = true
+	err = p.Save(600, 400, filepath.Join(dir, "trend.png"))
+	if err != nil {
+		return err
+	}
+	p = plot.New()
+	p.Title.Text = "Error rate trend"
+	p.Y.Label.Text = "error rate (%)"
+	p.X.Label.Text = "run"
+	p.NominalX(trend.Names...)
+	err = plotutil.AddLinePoints(p, "error rate", points(func(s Stats) float64 { return errorRate(s) * 100 }))
+	if err != nil {
+		return err
+	}
+	return p.Save(600, 400, filepath.Join(dir, "trend-errors.png"))
+}
@@ -0,0 +1,183 @@
+package bench_test
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/thiagonache/bench"
+)
+
+func writeStatsFile(t *testing.T, path string, stats ...bench.Stats) {
+	t.Helper()
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	err = bench.WriteStatsFileJSON(f, bench.StatsFile{Stats: stats})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestReadTrend_ReadsSameRunFromEachFile(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	err := os.WriteFile(dir+"/1.txt", []byte("http://fake.url,10,10,0,1,2,3\n"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeStatsFile(t, dir+"/2.json", bench.Stats{URL: "http://other.url", Requests: 1}, bench.Stats{URL: "http://fake.url", Requests: 20})
+	writeStatsFile(t, dir+"/3.json", bench.Stats{URL: "http://renamed.url", Requests: 30})
+	trend, err := bench.ReadTrend(dir+"/1.txt", dir+"/2.json", dir+"/3.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"1.txt", "2.json", "3.json"}
+	if !cmp.Equal(want, trend.Names) {
+		t.Error(cmp.Diff(want, trend.Names))
+	}
+	got := []int{}
+	for _, run := range trend.Runs {
+		got = append(got, run.Requests)
+	}
+	if !cmp.Equal([]int{10, 20, 30}, got) {
+		t.Error(cmp.Diff([]int{10, 20, 30}, got))
+	}
+}
+
+func TestReadTrend_NamesFilesOfTheSameNameByTheirDirectories(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	for _, sub := range []string{"/mon", "/tue", "/archive/mon"} {
+		err := os.MkdirAll(dir+sub, 0755)
+		if err != nil {
+			t.Fatal(err)
+		}
+		writeStatsFile(t, dir+sub+"/stats.json", bench.Stats{URL: "http://fake.url"})
+	}
+	writeStatsFile(t, dir+"/wed.json", bench.Stats{URL: "http://fake.url"})
+	trend, err := bench.ReadTrend(dir+"/archive/mon/stats.json", dir+"/mon/stats.json", dir+"/tue/stats.json", dir+"/wed.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		filepath.Join("archive", "mon", "stats.json"),
+		filepath.Join(filepath.Base(dir), "mon", "stats.json"),
+		filepath.Join("tue", "stats.json"),
+		"wed.json",
+	}
+	if !cmp.Equal(want, trend.Names) {
+		t.Error(cmp.Diff(want, trend.Names))
+	}
+}
+
+func TestReadTrend_ErrorsIfFileLacksRun(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	writeStatsFile(t, dir+"/1.json", bench.Stats{URL: "http://fake.url"})
+	writeStatsFile(t, dir+"/2.json", bench.Stats{URL: "http://a.url"}, bench.Stats{URL: "http://b.url"})
+	_, err := bench.ReadTrend(dir+"/1.json", dir+"/2.json")
+	if !errors.Is(err, bench.ErrNoMatchingStats) {
+		t.Errorf("want ErrNoMatchingStats, got %v", err)
+	}
+}
+
+func TestWriteTrend_PrintsEveryMetricAcrossRuns(t *testing.T) {
+	t.Parallel()
+	trend := bench.Trend{
+		Names: []string{"mon", "tue", "wed"},
+		Runs: []bench.Stats{
+			{
+				Requests:    100,
+				Successes:   100,
+				P50:         10,
+				P90:         20,
+				P99:         30,
+				Percentiles: []bench.Percentile{{P: 75, Value: 15}},
+				StatusCodes: map[int]int{200: 100},
+				Duration:    10 * time.Second,
+			},
+			{
+				Requests:    100,
+				Successes:   98,
+				Failures:    2,
+				P50:         11,
+				P90:         22,
+				P99:         33,
+				Errors:      map[string]int{bench.ErrorClassTimeout: 2},
+				StatusCodes: map[int]int{200: 98},
+				Duration:    10 * time.Second,
+			},
+			{
+				Requests:    200,
+				Successes:   200,
+				P50:         9,
+				P90:         18,
+				P99:         27,
+				Percentiles: []bench.Percentile{{P: 75, Value: 13}},
+				StatusCodes: map[int]int{200: 199, 201: 1},
+				Duration:    10 * time.Second,
+			},
+		},
+	}
+	buf := &bytes.Buffer{}
+	err := bench.WriteTrend(buf, trend)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `metric          mon       tue       wed
+requests        100       100       200
+successes       100       98        200
+failures        0         2         0
+error rate      0.00%     2.00%     0.00%
+req/s           10.00     10.00     20.00
+mean            0.000ms   0.000ms   0.000ms
+min             0.000ms   0.000ms   0.000ms
+max             0.000ms   0.000ms   0.000ms
+stddev          0.000ms   0.000ms   0.000ms
+P50             10.000ms  11.000ms  9.000ms
+P90             20.000ms  22.000ms  18.000ms
+P99             30.000ms  33.000ms  27.000ms
+P75             15.000ms  -         13.000ms
+request bytes   0         0         0
+response bytes  0         0         0
+bytes/s         0.00      0.00      0.00
+duration        10s       10s       10s
+error:timeout   0         2         0
+status:200      100       98        199
+status:201      0         0         1
+`
+	got := buf.String()
+	if want != got {
+		t.Error(cmp.Diff(want, got))
+	}
+}
+
+func TestTrendGraphs_DrawsLatencyAndErrorRate(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	trend := bench.Trend{
+		Names: []string{"1", "2", "3"},
+		Runs: []bench.Stats{
+			{Requests: 10, P50: 1, P90: 2, P99: 3},
+			{Requests: 10, Failures: 1, P50: 2, P90: 3, P99: 4},
+			{Requests: 10, P50: 1, P90: 2, P99: 5},
+		},
+	}
+	err := bench.TrendGraphs(dir, trend)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"trend.png", "trend-errors.png"} {
+		_, err = os.Stat(dir + "/" + name)
+		if err != nil {
+			t.Error(err)
+		}
+	}
+}
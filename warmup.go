@@ -0,0 +1,139 @@
+package bench
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// WithWarmup sends requests for d before the benchmark starts. Warm-up
+// requests are not part of the run's statistics.
+func WithWarmup(d time.Duration) Option {
+	return func(t *Tester) error {
+		if d < 0 {
+			return fmt.Errorf("%v is invalid warm-up duration", d)
+		}
+		t.warmup = d
+		return nil
+	}
+}
+
+// WithWarmupRequests sends n requests before the benchmark starts. Warm-up
+// requests are not part of the run's statistics.
+func WithWarmupRequests(n int) Option {
+	return func(t *Tester) error {
+		if n < 0 {
+			return fmt.Errorf("%d is invalid number of warm-up requests", n)
+		}
+		t.warmupRequests = n
+		return nil
+	}
+}
+
+// WithWarmupReport prints the statistics of the warm-up requests along with
+// the benchmark's.
+func WithWarmupReport(report bool) Option {
+	return func(t *Tester) error {
+		t.warmupReport = report
+		return nil
+	}
+}
+
+func (t Tester) Warmup() time.Duration {
+	return t.warmup
+}
+
+func (t Tester) WarmupRequests() int {
+	return t.warmupRequests
+}
+
+func (t *Tester) setupWarmup() error {
+	if t.warmup == 0 && t.warmupRequests == 0 {
+		return nil
+	}
+	if len(t.replay) > 0 {
+		return errors.New("warm-up and replay cannot be used together")
+	}
+	recorder, err := t.newRecorder()
+	if err != nil {
+		return err
+	}
+	t.warmupStats = &endpointStats{
+		mu:           &sync.Mutex{},
+		TimeRecorder: recorder,
+	}
+	return nil
+}
+
+// warmUp sends requests as fast as Concurrency workers allow, until the
+// warm-up time or requests run out, whichever comes first.
+func (t *Tester) warmUp() {
+	if t.warmupStats == nil {
+		return
+	}
+	start := time.Now()
+	deadline := start.Add(t.warmup)
+	mu := &sync.Mutex{}
+	remaining := t.warmupRequests
+	next := func() bool {
+		select {
+		case <-t.aborted:
+			return false
+		default:
+		}
+		if t.warmup > 0 && !time.Now().Before(deadline) {
+			return false
+		}
+		if t.warmupRequests > 0 {
+			mu.Lock()
+			defer mu.Unlock()
+			if remaining == 0 {
+				return false
+			}
+			remaining--
+		}
+		return true
+	}
+	wg := &sync.WaitGroup{}
+	wg.Add(t.Concurrency)
+	for x := 0; x < t.Concurrency; x++ {
+		go func() {
+			defer wg.Done()
+			renderer := t.newRenderer()
+			for next() {
+				e, ok := t.source.Next()
+				if !ok {
+					return
+				}
+				r := t.send(renderer, e, time.Now())
+				statsGroup{t.warmupStats}.record(r)
+				if r.err != nil {
+					t.LogFStdErr("warm-up: %v\n", r.err)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	t.warmupStats.mu.Lock()
+	t.warmupStats.stats.Duration = time.Since(start)
+	t.warmupStats.mu.Unlock()
+	// The benchmark numbers its requests from zero, as if there had been
+	// no warm-up.
+	t.mu.Lock()
+	t.seq = 0
+	t.mu.Unlock()
+}
+
+func (t *Tester) warmupMetrics() *Stats {
+	if t.warmupStats == nil {
+		return nil
+	}
+	t.warmupStats.mu.Lock()
+	stats := t.warmupStats.stats
+	t.warmupStats.mu.Unlock()
+	if t.warmupStats.TimeRecorder.Count() > 0 {
+		t.setRecorderMetrics(&stats, t.warmupStats.TimeRecorder)
+	}
+	return &stats
+}
@@ -0,0 +1,188 @@
+package bench_test
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/thiagonache/bench"
+)
+
+func TestRun_LeavesWarmupRequestsOutOfStats(t *testing.T) {
+	t.Parallel()
+	mu := &sync.Mutex{}
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		calls++
+		cold := calls <= 3
+		mu.Unlock()
+		if cold {
+			rw.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer server.Close()
+	tester, err := bench.NewTester(
+		bench.WithURL(server.URL),
+		bench.WithRequests(5),
+		bench.WithWarmupRequests(3),
+		bench.WithStdout(io.Discard),
+		bench.WithStderr(io.Discard),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = tester.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	mu.Lock()
+	sent := calls
+	mu.Unlock()
+	if sent != 8 {
+		t.Errorf("want 8 requests sent, got %d", sent)
+	}
+	stats := tester.Stats()
+	if stats.Requests != 5 || stats.Successes != 5 || stats.Failures != 0 {
+		t.Errorf("want 5 successful requests, got %d requests, %d successes and %d failures",
+			stats.Requests, stats.Successes, stats.Failures)
+	}
+	if tester.TimeRecorder.Count() != 5 {
+		t.Errorf("want 5 latencies recorded, got %d", tester.TimeRecorder.Count())
+	}
+	if stats.Warmup == nil {
+		t.Fatal("want warm-up stats")
+	}
+	if stats.Warmup.Requests != 3 || stats.Warmup.Failures != 3 {
+		t.Errorf("want 3 failed warm-up requests, got %d requests and %d failures",
+			stats.Warmup.Requests, stats.Warmup.Failures)
+	}
+}
+
+func TestRun_WarmsUpForDuration(t *testing.T) {
+	t.Parallel()
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		time.Sleep(time.Millisecond)
+	}))
+	defer server.Close()
+	tester, err := bench.NewTester(
+		bench.WithURL(server.URL),
+		bench.WithRequests(2),
+		bench.WithWarmup(50*time.Millisecond),
+		bench.WithStdout(io.Discard),
+		bench.WithStderr(io.Discard),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = tester.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stats := tester.Stats()
+	if stats.Requests != 2 {
+		t.Errorf("want 2 requests, got %d", stats.Requests)
+	}
+	if stats.Warmup == nil || stats.Warmup.Requests == 0 {
+		t.Fatal("want warm-up requests")
+	}
+	if stats.Warmup.Duration < 50*time.Millisecond {
+		t.Errorf("want warm-up to last at least 50ms, got %v", stats.Warmup.Duration)
+	}
+}
+
+func TestRun_PrintsWarmupOnlyIfReported(t *testing.T) {
+	t.Parallel()
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+	for _, report := range []bool{false, true} {
+		stdout := &bytes.Buffer{}
+		tester, err := bench.NewTester(
+			bench.WithURL(server.URL),
+			bench.WithWarmupRequests(2),
+			bench.WithWarmupReport(report),
+			bench.WithStdout(stdout),
+			bench.WithStderr(io.Discard),
+		)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = tester.Run()
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := strings.Contains(stdout.String(), "Warm-up: Requests: 2 Success: 2 Failures: 0")
+		if report != got {
+			t.Errorf("report %v: want warm-up line %v, got %q", report, report, stdout.String())
+		}
+	}
+}
+
+func TestFromArgs_SetsWarmup(t *testing.T) {
+	t.Parallel()
+	tester, err := bench.NewTester(
+		bench.FromArgs([]string{"run", "-u", "http://fake.url", "-warmup", "10s", "-warmup-requests", "20"}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tester.Warmup() != 10*time.Second {
+		t.Errorf("want warm-up 10s, got %v", tester.Warmup())
+	}
+	if tester.WarmupRequests() != 20 {
+		t.Errorf("want 20 warm-up requests, got %d", tester.WarmupRequests())
+	}
+}
+
+func TestNewTester_ErrorsIfWarmupIsInvalid(t *testing.T) {
+	t.Parallel()
+	tcs := []struct {
+		name string
+		opts []bench.Option
+	}{
+		{
+			name: "negative duration",
+			opts: []bench.Option{bench.WithURL("http://fake.url"), bench.WithWarmup(-time.Second)},
+		},
+		{
+			name: "negative requests",
+			opts: []bench.Option{bench.WithURL("http://fake.url"), bench.WithWarmupRequests(-1)},
+		},
+		{
+			name: "with replay",
+			opts: []bench.Option{bench.WithReplayFile("testdata/replay.har"), bench.WithWarmupRequests(1)},
+		},
+	}
+	for _, tc := range tcs {
+		_, err := bench.NewTester(tc.opts...)
+		if err == nil {
+			t.Errorf("%s: want error", tc.name)
+		}
+	}
+}
+
+func TestRun_NoWarmupStatsByDefault(t *testing.T) {
+	t.Parallel()
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+	tester, err := bench.NewTester(
+		bench.WithURL(server.URL),
+		bench.WithStdout(io.Discard),
+		bench.WithStderr(io.Discard),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = tester.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tester.Stats().Warmup != nil {
+		t.Errorf("want no warm-up stats, got %+v", tester.Stats().Warmup)
+	}
+}